@@ -15,8 +15,10 @@
 package anna_b112
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"regexp"
 	"strings"
 	"time"
@@ -31,54 +33,171 @@ var (
 	UARTSpeed = 115200
 )
 
-var responseStringPattern = regexp.MustCompile(`"[^"]+"|OK`)
+// ErrBusNotFound is returned when the serial device is not present.
+var ErrBusNotFound = armoryctl.ErrBusNotFound
+
+// CommandError is returned when an AT command completes with an ERROR or
+// +CME ERROR final result code, the error code is available when reported.
+type CommandError = armoryctl.CommandError
+
+// Retry sets the AT command retry policy, the global one is used when nil
+// (see armoryctl.Retry), only commands listed in RetryableCmd are retried.
+var Retry *armoryctl.RetryPolicy
+
+// RetryableCmd represents the AT commands (e.g. "+CGMI" for AT+CGMI) which
+// only query the module, only those are retried on transient errors (see
+// Retry) as a timed out command might have been executed.
+var RetryableCmd = map[string]bool{
+	"":        true, // AT
+	"+CGMI":   true,
+	"+CGMM":   true,
+	"+CGSN":   true,
+	"+CGMR":   true,
+	"+UBTLN?": true,
+	"+UBTDM?": true,
+	"+UBTPM?": true,
+	"+UBTCM?": true,
+	"+UBTLE?": true,
+}
+
+var responseStringPattern = regexp.MustCompile(`"[^"]+"`)
 
-func sendATCmd(cmd string) (response string, err error) {
-	response, err = armoryctl.UARTWrite(UARTPath, UARTSpeed, "AT"+cmd+"\r")
+// Command sends an AT command (e.g. "+CGMI" for AT+CGMI) and returns its
+// intermediate response lines, the final result code is returned as error
+// when not OK. Only commands listed in RetryableCmd are retried.
+func Command(cmd string) (lines []string, err error) {
+	return CommandContext(context.Background(), cmd)
+}
+
+// CommandContext is like Command but the command is aborted once ctx is done.
+func CommandContext(ctx context.Context, cmd string) (lines []string, err error) {
+	res, err := command(ctx, cmd)
 
 	if err != nil {
 		return
 	}
 
-	m := responseStringPattern.FindStringSubmatch(response)
+	return res.Lines, nil
+}
 
-	if len(m) == 1 {
-		response = m[0]
+// command sends an AT command, retrying it on transient errors according to
+// Retry when listed in RetryableCmd. Unsolicited result codes received
+// before the final result code are discarded from the response lines.
+func command(ctx context.Context, cmd string) (res *armoryctl.ATResponse, err error) {
+	if RetryableCmd[cmd] {
+		err = Retry.Do(ctx, func() (err error) {
+			res, err = armoryctl.UARTCommandContext(ctx, UARTPath, UARTSpeed, "AT"+cmd, 0)
+			return
+		})
 	} else {
-		err = errors.New("response error")
+		res, err = armoryctl.UARTCommandContext(ctx, UARTPath, UARTSpeed, "AT"+cmd, 0)
+	}
+
+	if res == nil {
+		return
 	}
 
-	return response, err
+	var lines []string
+
+	for _, line := range res.Lines {
+		if IsURC(line) {
+			armoryctl.Log(slog.LevelDebug, "unsolicited result code discarded", "command", "AT"+cmd, "urc", line)
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	res = &armoryctl.ATResponse{
+		Lines:  lines,
+		Result: res.Result,
+	}
+
+	return
+}
+
+// sendATCmd returns the first quoted string in the command response, or the
+// final result code when the response has none.
+func sendATCmd(ctx context.Context, cmd string) (response string, err error) {
+	res, err := command(ctx, cmd)
+
+	if err != nil {
+		return
+	}
+
+	for _, line := range res.Lines {
+		if m := responseStringPattern.FindString(line); m != "" {
+			return m, nil
+		}
+	}
+
+	return res.Result, nil
 }
 
 // Get device manufacturer (AT+CGMI).
 func GetDeviceManufacturer() (manufacturer string, err error) {
-	return sendATCmd("+CGMI")
+	return GetDeviceManufacturerContext(context.Background())
+}
+
+// GetDeviceManufacturerContext is like GetDeviceManufacturer but the command is
+// aborted once ctx is done.
+func GetDeviceManufacturerContext(ctx context.Context) (manufacturer string, err error) {
+	return sendATCmd(ctx, "+CGMI")
 }
 
 // Get device model (AT+CGMM).
 func GetDeviceModel() (model string, err error) {
-	return sendATCmd("+CGMM")
+	return GetDeviceModelContext(context.Background())
+}
+
+// GetDeviceModelContext is like GetDeviceModel but the command is aborted once
+// ctx is done.
+func GetDeviceModelContext(ctx context.Context) (model string, err error) {
+	return sendATCmd(ctx, "+CGMM")
 }
 
 // Get product serial number (AT+CGSN).
 func GetDeviceSerial() (model string, err error) {
-	return sendATCmd("+CGSN")
+	return GetDeviceSerialContext(context.Background())
+}
+
+// GetDeviceSerialContext is like GetDeviceSerial but the command is aborted
+// once ctx is done.
+func GetDeviceSerialContext(ctx context.Context) (model string, err error) {
+	return sendATCmd(ctx, "+CGSN")
 }
 
 // Get software version (AT+CGMR).
 func GetSoftwareVersion() (version string, err error) {
-	return sendATCmd("+CGMR")
+	return GetSoftwareVersionContext(context.Background())
+}
+
+// GetSoftwareVersionContext is like GetSoftwareVersion but the command is
+// aborted once ctx is done.
+func GetSoftwareVersionContext(ctx context.Context) (version string, err error) {
+	return sendATCmd(ctx, "+CGMR")
 }
 
 // Get device name (AT+UBTLN?).
 func GetDeviceName() (name string, err error) {
-	return sendATCmd("+UBTLN?")
+	return GetDeviceNameContext(context.Background())
+}
+
+// GetDeviceNameContext is like GetDeviceName but the command is aborted once
+// ctx is done.
+func GetDeviceNameContext(ctx context.Context) (name string, err error) {
+	return sendATCmd(ctx, "+UBTLN?")
 }
 
 // Set Bluetooth device name (AT+UBTLN="device name") and parmanently store
 // the current configuration (AT+&W, AT+CPWROFF).
 func SetDeviceName(name string) (err error) {
+	return SetDeviceNameContext(context.Background(), name)
+}
+
+// SetDeviceNameContext is like SetDeviceName but the commands are aborted once
+// ctx is done.
+func SetDeviceNameContext(ctx context.Context, name string) (err error) {
 	if len(name) > 31 {
 		return errors.New("name must be a valid UTF-8 string no longer than 31 bytes")
 	}
@@ -90,7 +209,7 @@ func SetDeviceName(name string) (err error) {
 	cmds := [3]string{"+UBTLN=\"" + name + "\"", "&W", "+CPWROFF"}
 
 	for _, cmd := range cmds {
-		_, err = sendATCmd(cmd)
+		_, err = sendATCmd(ctx, cmd)
 
 		if err != nil {
 			return
@@ -103,31 +222,36 @@ func SetDeviceName(name string) (err error) {
 // Assemble the device identification string from device manufacturer, model,
 // product serial, software version and Bluetooth device name.
 func Info() (id string, err error) {
-	manufacturer, err := GetDeviceManufacturer()
+	return InfoContext(context.Background())
+}
+
+// InfoContext is like Info but the commands are aborted once ctx is done.
+func InfoContext(ctx context.Context) (id string, err error) {
+	manufacturer, err := GetDeviceManufacturerContext(ctx)
 
 	if err != nil {
 		manufacturer = fmt.Sprintf("error:(%s)", err)
 	}
 
-	model, err := GetDeviceModel()
+	model, err := GetDeviceModelContext(ctx)
 
 	if err != nil {
 		model = fmt.Sprintf("error:(%s)", err)
 	}
 
-	serial, err := GetDeviceSerial()
+	serial, err := GetDeviceSerialContext(ctx)
 
 	if err != nil {
 		serial = fmt.Sprintf("error:(%s)", err)
 	}
 
-	version, err := GetSoftwareVersion()
+	version, err := GetSoftwareVersionContext(ctx)
 
 	if err != nil {
 		version = fmt.Sprintf("error:(%s)", err)
 	}
 
-	name, err := GetDeviceName()
+	name, err := GetDeviceNameContext(ctx)
 
 	if err != nil {
 		name = fmt.Sprintf("error:(%s)", err)
@@ -140,6 +264,16 @@ func Info() (id string, err error) {
 
 // Reset the BLE module by toggling the RESET_N pin (GPIO 9).
 func Reset() (err error) {
+	return ResetContext(context.Background())
+}
+
+// ResetContext is like Reset but the reset grace time is interrupted once ctx
+// is done, RESET_N is always released.
+func ResetContext(ctx context.Context) (err error) {
+	if err = armoryctl.InitBLE(); err != nil {
+		return
+	}
+
 	err = armoryctl.GPIOSetOutput("GPIO9", false)
 
 	if err != nil {
@@ -147,19 +281,28 @@ func Reset() (err error) {
 	}
 
 	// grace time to ensure reset triggering
-	time.Sleep(1 * time.Second)
+	sleepErr := armoryctl.Sleep(ctx, 1*time.Second)
+
+	if err = armoryctl.GPIOSetOutput("GPIO9", true); err != nil {
+		return
+	}
 
-	return armoryctl.GPIOSetOutput("GPIO9", true)
+	return sleepErr
 }
 
 // Toggle BLE visibility to non discoverable (AT+UBTDM=1), non pairable
 // (AT+UBTPM=1), non connectable (AT+UBTCM=1) and disable any BLE role
 // (AT+UBTLE=0), finally permanently store current configuration (AT&W, AT+CPWROFF).
 func Disable() (err error) {
+	return DisableContext(context.Background())
+}
+
+// DisableContext is like Disable but the commands are aborted once ctx is done.
+func DisableContext(ctx context.Context) (err error) {
 	cmds := [6]string{"+UBTDM=1", "+UBTPM=1", "+UBTCM=1", "+UBTLE=0", "&W", "+CPWROFF"}
 
 	for _, cmd := range cmds {
-		_, err = sendATCmd(cmd)
+		_, err = sendATCmd(ctx, cmd)
 
 		if err != nil {
 			return
@@ -173,10 +316,15 @@ func Disable() (err error) {
 // (AT+UBTPM=2), connectable (AT+UBTCM=2) and set BLE role to peripheral
 // (AT+UBTLE=2), finally permanently store current configuration (AT&W, AT+CPWROFF).
 func Enable() (err error) {
+	return EnableContext(context.Background())
+}
+
+// EnableContext is like Enable but the commands are aborted once ctx is done.
+func EnableContext(ctx context.Context) (err error) {
 	cmds := [6]string{"+UBTDM=3", "+UBTPM=2", "+UBTCM=2", "+UBTLE=2", "&W", "+CPWROFF"}
 
 	for _, cmd := range cmds {
-		_, err = sendATCmd(cmd)
+		_, err = sendATCmd(ctx, cmd)
 
 		if err != nil {
 			return
@@ -189,6 +337,16 @@ func Enable() (err error) {
 // Enter bootloader mode by driving low SWITCH_1 (GPIO 27) and
 // SWITCH_2 (GPIO 26) during a module reset cycle.
 func EnterBootloaderMode() (err error) {
+	return EnterBootloaderModeContext(context.Background())
+}
+
+// EnterBootloaderModeContext is like EnterBootloaderMode but the module reset
+// cycle is interrupted once ctx is done.
+func EnterBootloaderModeContext(ctx context.Context) (err error) {
+	if err = armoryctl.InitBLE(); err != nil {
+		return
+	}
+
 	err = armoryctl.GPIOSetOutput("GPIO26", false)
 
 	if err != nil {
@@ -201,12 +359,22 @@ func EnterBootloaderMode() (err error) {
 		return
 	}
 
-	return Reset()
+	return ResetContext(ctx)
 }
 
 // Enter normal mode by driving high SWITCH_1 (GPIO 27) and
 // SWITCH_2 (GPIO 26) during a module reset cycle.
 func EnterNormalMode() (err error) {
+	return EnterNormalModeContext(context.Background())
+}
+
+// EnterNormalModeContext is like EnterNormalMode but the module reset
+// cycle is interrupted once ctx is done.
+func EnterNormalModeContext(ctx context.Context) (err error) {
+	if err = armoryctl.InitBLE(); err != nil {
+		return
+	}
+
 	err = armoryctl.GPIOSetOutput("GPIO26", true)
 
 	if err != nil {
@@ -219,20 +387,26 @@ func EnterNormalMode() (err error) {
 		return
 	}
 
-	return Reset()
+	return ResetContext(ctx)
 }
 
 // Set the low frequency clock source to the internal RC with default
 // parameters recommended by Nordic SDK, using the AT+UPRODLFCLK command.
 // (see nRF5_SDK_15.3.0_59ac345/components/softdevice/s132/headers/nrf_sdm.h).
 func ATSetInternalRCLFCK() (err error) {
-	_, err = sendATCmd("+UPROD=1")
+	return ATSetInternalRCLFCKContext(context.Background())
+}
+
+// ATSetInternalRCLFCKContext is like ATSetInternalRCLFCK but the commands are
+// aborted once ctx is done.
+func ATSetInternalRCLFCKContext(ctx context.Context) (err error) {
+	_, err = sendATCmd(ctx, "+UPROD=1")
 
 	if err != nil {
 		return
 	}
 
-	_, err = sendATCmd("+UPRODLFCLK=0,16,2")
+	_, err = sendATCmd(ctx, "+UPRODLFCLK=0,16,2")
 
 	return
 }
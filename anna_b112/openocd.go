@@ -10,12 +10,18 @@
 //   https://www.u-blox.com/sites/default/files/ANNA-B112_DataSheet_%28UBX-18011707%29.pdf
 //   https://github.com/usbarmory/usbarmory/wiki/Bluetooth
 
+// +build linux
+
 package anna_b112
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -35,6 +41,10 @@ var (
 	// directly, otherwise it is searched in the directories named by the
 	// PATH environment variable.
 	OpenOCDPath = "openocd"
+
+	// OpenOCDOutput, when not nil, is called with each OpenOCD output line
+	// to report progress.
+	OpenOCDOutput func(line string)
 )
 
 const (
@@ -116,14 +126,24 @@ type manifestEntry struct {
 	} `json:"manifest"`
 }
 
-func getBootloader(path string, archive string) (bootloader []byte, err error) {
-	err = armoryctl.UnzipFile(filepath.Join(path, archive), path)
+func getBootloader(fsys fs.FS, archive string) (bootloader []byte, err error) {
+	buf, err := fs.ReadFile(fsys, archive)
 
 	if err != nil {
 		return
 	}
 
-	j, err := os.ReadFile(filepath.Join(path, updateManifest))
+	r, err := zip.NewReader(bytes.NewReader(buf), int64(len(buf)))
+
+	if err != nil {
+		return
+	}
+
+	if fsys, err = armoryctl.UnzipFS(r); err != nil {
+		return
+	}
+
+	j, err := fs.ReadFile(fsys, updateManifest)
 
 	if err != nil {
 		return
@@ -141,11 +161,11 @@ func getBootloader(path string, archive string) (bootloader []byte, err error) {
 		return
 	}
 
-	return os.ReadFile(filepath.Join(path, m.Manifest.Bootloader.BinFile))
+	return fs.ReadFile(fsys, m.Manifest.Bootloader.BinFile)
 }
 
-func getConfig(path string) (config []byte, err error) {
-	configFile, err := filepath.Glob(filepath.Join(path, updateConfig))
+func getConfig(fsys fs.FS) (config []byte, err error) {
+	configFile, err := fs.Glob(fsys, updateConfig)
 
 	if err != nil {
 		return
@@ -156,12 +176,10 @@ func getConfig(path string) (config []byte, err error) {
 		return
 	}
 
-	return os.ReadFile(configFile[0])
+	return fs.ReadFile(fsys, configFile[0])
 }
 
-func prepareImage(path string, output string) (err error) {
-	path = filepath.Join(path, "uart")
-
+func prepareImage(fsys fs.FS, output string) (err error) {
 	var c []configurationEntry
 	var flash [flashSize]byte
 
@@ -169,7 +187,11 @@ func prepareImage(path string, output string) (err error) {
 		flash[i] = 0xff
 	}
 
-	j, err := getConfig(path)
+	if fsys, err = fs.Sub(fsys, "uart"); err != nil {
+		return
+	}
+
+	j, err := getConfig(fsys)
 
 	if err != nil {
 		return
@@ -205,7 +227,7 @@ func prepareImage(path string, output string) (err error) {
 	}
 
 	tag := config[bootloaderTag]
-	bootloader, err := getBootloader(path, tag.File)
+	bootloader, err := getBootloader(fsys, tag.File)
 
 	if err != nil {
 		return
@@ -214,7 +236,7 @@ func prepareImage(path string, output string) (err error) {
 	copy(flash[bootloaderOffset:], bootloader)
 
 	tag = config[connectivitySoftwareTag]
-	connectivitySoftware, err := os.ReadFile(filepath.Join(path, tag.File))
+	connectivitySoftware, err := fs.ReadFile(fsys, tag.File)
 
 	if err != nil {
 		return
@@ -229,7 +251,7 @@ func prepareImage(path string, output string) (err error) {
 	copy(flash[addr:], connectivitySoftware)
 
 	tag = config[softDeviceTag]
-	softDevice, err := os.ReadFile(filepath.Join(path, tag.File))
+	softDevice, err := fs.ReadFile(fsys, tag.File)
 
 	if err != nil {
 		return
@@ -324,7 +346,7 @@ func patchUICR(inputPath string) (outputPath string, err error) {
 	return
 }
 
-func execOpenOCD(flashPath string, uicrPath string, template string, retry bool) (err error) {
+func execOpenOCD(ctx context.Context, flashPath string, uicrPath string, template string, retry bool) (err error) {
 	openocd, interfacePath, transportPath, tmpDir, err := initOpenOCD()
 
 	if err != nil {
@@ -337,13 +359,13 @@ func execOpenOCD(flashPath string, uicrPath string, template string, retry bool)
 
 	args := []string{"-f", interfacePath, "-f", transportPath, "-c", cmd}
 
-	_, err = armoryctl.ExecCommand(openocd, args, true, "")
+	_, err = armoryctl.ExecCommandStream(ctx, openocd, args, true, "", OpenOCDOutput)
 
-	if retry && err != nil {
+	if retry && err != nil && ctx.Err() == nil {
 		// The first time the flash command is executed after a mass_erase it
 		// can fail, we ignore any error at first attempt and re-execute the
 		// same command a second time.
-		_, err = armoryctl.ExecCommand(openocd, args, true, "")
+		_, err = armoryctl.ExecCommandStream(ctx, openocd, args, true, "", OpenOCDOutput)
 	}
 
 	return
@@ -351,6 +373,11 @@ func execOpenOCD(flashPath string, uicrPath string, template string, retry bool)
 
 // Backup the nrf52.flash and nrf52.uicr regions.
 func Backup() (flashPath string, uicrPath string, err error) {
+	return BackupContext(context.Background())
+}
+
+// BackupContext is like Backup but OpenOCD is terminated once ctx is done.
+func BackupContext(ctx context.Context) (flashPath string, uicrPath string, err error) {
 	cachePath, err := initCache()
 
 	if err != nil {
@@ -361,7 +388,7 @@ func Backup() (flashPath string, uicrPath string, err error) {
 	flashPath = filepath.Join(cachePath, fmt.Sprintf("flash-%d.bin", time))
 	uicrPath = filepath.Join(cachePath, fmt.Sprintf("UICR-%d.bin", time))
 
-	err = execOpenOCD(flashPath, uicrPath, readFlashTemplate, false)
+	err = execOpenOCD(ctx, flashPath, uicrPath, readFlashTemplate, false)
 
 	return
 }
@@ -371,27 +398,33 @@ func Backup() (flashPath string, uicrPath string, err error) {
 // flash and uicr regions (including module MAC address and/or any other
 // configuration) will be lost.
 func Flash(flashPath string, uicrPath string) (err error) {
-	return execOpenOCD(flashPath, uicrPath, writeFlashTemplate, true)
+	return FlashContext(context.Background(), flashPath, uicrPath)
+}
+
+// FlashContext is like Flash but OpenOCD is terminated once ctx is done,
+// *IMPORTANT*: an interrupted write leaves the module with erased or partially
+// written flash and uicr regions.
+func FlashContext(ctx context.Context, flashPath string, uicrPath string) (err error) {
+	return execOpenOCD(ctx, flashPath, uicrPath, writeFlashTemplate, true)
 }
 
 // Update the ANNA-B112 firmware, a backup of the current flash and UICR region
 // is created before overwriting them. The update also performs the operation
 // described in FlashSetInternalRCLFCK.
 func Update(updateFile string) (err error) {
-	tmpDir, err := os.MkdirTemp("", "update-")
-
-	if err != nil {
-		return
-	}
-	defer func() { _ = os.RemoveAll(tmpDir) }() // make errcheck happy
+	return UpdateContext(context.Background(), updateFile)
+}
 
+// UpdateContext is like Update but OpenOCD is terminated once ctx is done (see
+// FlashContext).
+func UpdateContext(ctx context.Context, updateFile string) (err error) {
 	cachePath, err := initCache()
 
 	if err != nil {
 		return
 	}
 
-	err = armoryctl.UnzipFile(updateFile, tmpDir)
+	fsys, err := armoryctl.UnzipFileFS(updateFile)
 
 	if err != nil {
 		return
@@ -399,13 +432,13 @@ func Update(updateFile string) (err error) {
 
 	flash := filepath.Join(cachePath, "flash.bin")
 
-	err = prepareImage(tmpDir, flash)
+	err = prepareImage(fsys, flash)
 
 	if err != nil {
 		return
 	}
 
-	_, uicr, err := Backup()
+	_, uicr, err := BackupContext(ctx)
 
 	if err != nil {
 		return
@@ -417,7 +450,7 @@ func Update(updateFile string) (err error) {
 		return
 	}
 
-	return Flash(flash, uicr)
+	return FlashContext(ctx, flash, uicr)
 }
 
 // Set the low frequency clock source to the internal RC with default
@@ -425,7 +458,13 @@ func Update(updateFile string) (err error) {
 // module flash.
 // (see nRF5_SDK_15.3.0_59ac345/components/softdevice/s132/headers/nrf_sdm.h).
 func FlashSetInternalRCLFCK() (err error) {
-	flash, uicr, err := Backup()
+	return FlashSetInternalRCLFCKContext(context.Background())
+}
+
+// FlashSetInternalRCLFCKContext is like FlashSetInternalRCLFCK but OpenOCD is
+// terminated once ctx is done (see FlashContext).
+func FlashSetInternalRCLFCKContext(ctx context.Context) (err error) {
+	flash, uicr, err := BackupContext(ctx)
 
 	if err != nil {
 		return
@@ -437,5 +476,5 @@ func FlashSetInternalRCLFCK() (err error) {
 		return
 	}
 
-	return Flash(flash, uicr)
+	return FlashContext(ctx, flash, uicr)
 }
@@ -0,0 +1,314 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+//
+// Links:
+//   https://www.u-blox.com/sites/default/files/u-connect-ATCommands-Manual_%28UBX-14044127%29.pdf
+
+package anna_b112
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/usbarmory/armoryctl/internal"
+)
+
+// Unsolicited result codes (URCs) of interest.
+const (
+	// module start-up completed
+	URCStartup = "+STARTUP"
+	// ACL connection established
+	URCConnected = "+UUBTACLC"
+	// ACL connection closed
+	URCDisconnected = "+UUBTACLD"
+	// bonding completed
+	URCBonded = "+UUBTB"
+)
+
+// URCBufferSize is the number of URCs buffered for each subscriber, further
+// URCs are dropped until the subscriber catches up.
+const URCBufferSize = 16
+
+// URC represents an unsolicited result code received from the module.
+type URC struct {
+	// Name is the URC prefix (e.g. "+UUBTACLC").
+	Name string
+	// Params holds the comma separated URC parameters, as received.
+	Params []string
+	// Time is the reception time.
+	Time time.Time
+}
+
+// IsURC returns whether a received line is an unsolicited result code, all
+// u-connect URCs are prefixed with +UU except +STARTUP.
+func IsURC(line string) bool {
+	return strings.HasPrefix(line, "+UU") || line == URCStartup
+}
+
+func parseURC(line string) (urc URC) {
+	name, params, found := strings.Cut(line, ":")
+
+	urc.Name = name
+	urc.Time = time.Now()
+
+	if found {
+		urc.Params = strings.Split(params, ",")
+	}
+
+	return
+}
+
+type subscriber struct {
+	names map[string]bool
+	ch    chan URC
+}
+
+// Session represents a long-lived AT command session with the module, which
+// owns the serial port, sends commands in order and dispatches unsolicited
+// result codes to subscribers.
+type Session struct {
+	// Timeout is the command response timeout (armoryctl.ATTimeout when
+	// zero).
+	Timeout time.Duration
+
+	path   string
+	speed  int
+	port   io.ReadWriteCloser
+	cancel context.CancelFunc
+
+	// serializes commands
+	cmd   sync.Mutex
+	lines chan string
+
+	// protects subs and err
+	mu   sync.Mutex
+	subs map[*subscriber]bool
+	done chan struct{}
+	err  error
+}
+
+// OpenSession opens the serial device at path and starts receiving the
+// module output.
+//
+// Session commands are recorded (see armoryctl.Recording) but, as the module
+// output is received asynchronously, they cannot be replayed: an error is
+// returned when a UART backend (e.g. trace replay) is set.
+func OpenSession(path string, speed int) (s *Session, err error) {
+	if armoryctl.HasUARTBackend() {
+		return nil, errors.New("sessions are not supported with a UART backend (e.g. trace replay)")
+	}
+
+	port, err := armoryctl.OpenUART(path, speed)
+
+	if err != nil {
+		return
+	}
+
+	return newSession(path, speed, port), nil
+}
+
+func newSession(path string, speed int, port io.ReadWriteCloser) (s *Session) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s = &Session{
+		path:   path,
+		speed:  speed,
+		port:   port,
+		cancel: cancel,
+		lines:  make(chan string, 64),
+		subs:   make(map[*subscriber]bool),
+		done:   make(chan struct{}),
+	}
+
+	go s.receive(ctx)
+
+	return
+}
+
+func (s *Session) receive(ctx context.Context) {
+	var err error
+	var line string
+
+	p := armoryctl.NewATPort(s.port)
+
+	for {
+		if line, err = p.ReadLine(ctx, time.Time{}); err != nil {
+			break
+		}
+
+		armoryctl.Log(armoryctl.LevelTrace, "AT receive", "line", line)
+
+		if IsURC(line) {
+			s.dispatch(parseURC(line))
+			continue
+		}
+
+		select {
+		case s.lines <- line:
+		default:
+			// no command is pending to consume the line
+		}
+	}
+
+	if errors.Is(err, context.Canceled) {
+		err = errors.New("session closed")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+
+	for sub := range s.subs {
+		close(sub.ch)
+		delete(s.subs, sub)
+	}
+
+	close(s.done)
+}
+
+func (s *Session) dispatch(urc URC) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sub := range s.subs {
+		if len(sub.names) > 0 && !sub.names[urc.Name] {
+			continue
+		}
+
+		select {
+		case sub.ch <- urc:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving the URCs matching the argument names
+// (e.g. URCConnected), or all URCs when none is given. The channel is closed
+// when the subscription is cancelled or the session ends.
+func (s *Session) Subscribe(names ...string) (urc <-chan URC, cancel func()) {
+	sub := &subscriber{
+		names: make(map[string]bool),
+		ch:    make(chan URC, URCBufferSize),
+	}
+
+	for _, name := range names {
+		sub.names[name] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		close(sub.ch)
+		return sub.ch, func() {}
+	default:
+	}
+
+	s.subs[sub] = true
+
+	cancel = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.subs[sub] {
+			close(sub.ch)
+			delete(s.subs, sub)
+		}
+	}
+
+	return sub.ch, cancel
+}
+
+// Command sends an AT command (e.g. "+CGMI" for AT+CGMI) and returns its
+// intermediate response lines, the final result code is returned as error
+// when not OK. Commands are sent in order, waiting for each response.
+func (s *Session) Command(cmd string) (lines []string, err error) {
+	return s.CommandContext(context.Background(), cmd)
+}
+
+// CommandContext is like Command but the command is aborted once ctx is done.
+func (s *Session) CommandContext(ctx context.Context, cmd string) (lines []string, err error) {
+	s.cmd.Lock()
+	defer s.cmd.Unlock()
+
+	// discard leftovers of previous timed out commands
+	for len(s.lines) > 0 {
+		<-s.lines
+	}
+
+	timeout := s.Timeout
+
+	if timeout == 0 {
+		timeout = armoryctl.ATTimeout
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var res *armoryctl.ATResponse
+
+	cmd = "AT" + cmd
+	start := time.Now()
+
+	defer func() {
+		attrs := []any{"command", cmd, armoryctl.LogDuration, time.Since(start)}
+
+		if err != nil {
+			attrs = append(attrs, "error", err)
+		}
+
+		armoryctl.Log(slog.LevelDebug, "AT command", attrs...)
+
+		if rec := armoryctl.Recording; rec != nil {
+			rec.UART(s.path, s.speed, cmd, res, err, start)
+		}
+	}()
+
+	if _, err = s.port.Write([]byte(cmd + "\r")); err != nil {
+		return
+	}
+
+	res, err = armoryctl.ReadATResponse(cmd, func() (string, error) {
+		select {
+		case line := <-s.lines:
+			return line, nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-timer.C:
+			return "", armoryctl.Classify(errors.New("response timeout"), armoryctl.ErrTimeout)
+		case <-s.done:
+			return "", s.err
+		}
+	})
+
+	if res != nil {
+		lines = res.Lines
+	}
+
+	return
+}
+
+// Done returns a channel which is closed when the session ends.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close ends the session and releases the serial port.
+func (s *Session) Close() (err error) {
+	s.cancel()
+	<-s.done
+
+	return s.port.Close()
+}
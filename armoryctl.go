@@ -11,18 +11,30 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 
 	"github.com/usbarmory/armoryctl/anna_b112"
 	"github.com/usbarmory/armoryctl/atecc608"
 	"github.com/usbarmory/armoryctl/fusb303"
+	"github.com/usbarmory/armoryctl/i2c"
 	"github.com/usbarmory/armoryctl/internal"
 	"github.com/usbarmory/armoryctl/led"
 	"github.com/usbarmory/armoryctl/pf1510"
@@ -30,9 +42,12 @@ import (
 )
 
 type Config struct {
-	debug  bool
-	force  bool
-	logger *log.Logger
+	debug   bool
+	trace   bool
+	force   bool
+	logFile string
+	record  string
+	replay  string
 }
 
 var conf *Config
@@ -55,12 +70,14 @@ LED control
 Type-C plug port controller (TUSB320)
   tusb id			# read controller identifier
   tusb current_mode		# read advertised current
+  tusb dump			# read and decode all registers
 
 Type-C receptacle port controller (FUSB303)
   fusb id			# read controller identifier
   fusb current_mode		# read advertised current
   fusb enable			# enable the controller
   fusb disable			# disable the controller
+  fusb dump			# read and decode all registers
 
 Bluetooth module (ANNA-B112)
   ble info			# read device information
@@ -72,22 +89,35 @@ Bluetooth module (ANNA-B112)
   ble rc_lfck (flash|at)	# set LF clock source to internal RC oscillator
   ble update <firmware path>	# module firmware update
   ble name <device name>	# set device name
+  ble monitor			# print unsolicited result codes (URCs)
 
 Secure Element (ATECC608A/ATECC608B)
   atecc info			# read device information
   atecc self_test		# execute self test procedure
+  atecc random <nbytes> [hex|base64|raw]	# read random data (default hex)
+  atecc rngd			# feed random data to the kernel entropy pool
+  atecc config [json]		# read and decode the configuration zone
+  atecc slots [json]		# describe key slots usage
+  atecc read <slot> [hex|base64|raw]	# read data zone slot (default hex)
 
 Power Management Integrated Circuit (PF1510)
   pmic info			# read device information
+  pmic dump			# read and decode all registers
+
+Board acceptance test
+  probe				# check identity of all on-board devices
+
+I²C bus access
+  i2c scan <bus>				# list addresses answering a read probe
+  i2c read <bus> <addr> <reg> <len>		# read registers
+  i2c write <bus> <addr> <reg> <bytes...>	# write registers
 `
 
 func init() {
 	log.SetOutput(os.Stdout)
 	log.SetFlags(0)
 
-	conf = &Config{
-		logger: log.New(os.Stdout, log.Prefix(), log.Flags()),
-	}
+	conf = &Config{}
 
 	cachePath := ""
 	usr, err := user.Current()
@@ -111,10 +141,16 @@ func init() {
 	}
 
 	flag.BoolVar(&conf.debug, "d", false, "debug")
+	flag.BoolVar(&conf.trace, "v", false, "debug with transfer payload dumps (implies -d)")
+	flag.StringVar(&conf.logFile, "g", "", "debug log file (JSON format), instead of stderr")
 	flag.BoolVar(&conf.force, "f", false, "skip hardware check and force execution")
+	flag.StringVar(&conf.record, "t", "", "record I²C/UART traffic to trace file")
+	flag.StringVar(&conf.replay, "T", "", "replay I²C/UART traffic from trace file (implies -f)")
+	flag.DurationVar(&armoryctl.LockTimeout, "w", armoryctl.LockTimeout, "wait timeout for locked buses")
 
 	flag.StringVar(&anna_b112.CachePath, "c", cachePath, "ANNA-B112 firmware cache path")
 	flag.StringVar(&anna_b112.OpenOCDPath, "x", anna_b112.OpenOCDPath, "OpenOCD lookpath")
+	flag.StringVar(&armoryctl.Escalation, "e", armoryctl.Escalation, "privilege escalation method for OpenOCD (sudo|doas|pkexec|none)")
 	flag.StringVar(&anna_b112.UARTPath, "u", anna_b112.UARTPath, "ANNA-B112 UART path")
 	flag.IntVar(&anna_b112.UARTSpeed, "s", anna_b112.UARTSpeed, "ANNA-B112 UART speed")
 
@@ -138,6 +174,606 @@ func checkModel() (match bool) {
 	return
 }
 
+// setLogger configures debug logging, on stderr to avoid cluttering command
+// output, or on a JSON log file.
+func setLogger() (err error) {
+	opts := &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}
+
+	if conf.trace {
+		opts.Level = armoryctl.LevelTrace
+	}
+
+	if conf.logFile == "" {
+		armoryctl.Logger = slog.New(slog.NewTextHandler(os.Stderr, opts))
+		return
+	}
+
+	f, err := os.OpenFile(conf.logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+
+	if err != nil {
+		return
+	}
+
+	armoryctl.Logger = slog.New(slog.NewJSONHandler(f, opts))
+
+	return
+}
+
+func record(path string) (err error) {
+	f, err := os.Create(path)
+
+	if err != nil {
+		return
+	}
+
+	armoryctl.Recording = armoryctl.NewRecorder(f)
+
+	return
+}
+
+func replay(path string) (err error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }() // make errcheck happy
+
+	trace, err := armoryctl.NewReplayer(f)
+
+	if err != nil {
+		return
+	}
+
+	for _, bus := range trace.Buses() {
+		armoryctl.SetI2CBackend(bus, trace.I2C(bus))
+	}
+
+	armoryctl.SetUARTBackend(trace)
+
+	return
+}
+
+// printOpenOCD reports OpenOCD progress on stderr, to avoid cluttering
+// command output.
+func printOpenOCD(line string) {
+	fmt.Fprintln(os.Stderr, line)
+}
+
+func monitorBLE() (err error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s, err := anna_b112.OpenSession(anna_b112.UARTPath, anna_b112.UARTSpeed)
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = s.Close() }() // make errcheck happy
+
+	urcs, cancel := s.Subscribe()
+	defer cancel()
+
+	for {
+		select {
+		case urc, ok := <-urcs:
+			if !ok {
+				return
+			}
+
+			log.Printf("%s %s %s", urc.Time.Format("15:04:05.000"), urc.Name, strings.Join(urc.Params, ","))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// knownDevice returns the name of the on-board chip configured at the
+// argument bus and address.
+func knownDevice(bus int, addr int) string {
+	devices := []struct {
+		name string
+		bus  int
+		addr int
+	}{
+		{"PF1510", pf1510.I2CBus, pf1510.I2CAddress},
+		{"FUSB303", fusb303.I2CBus, fusb303.I2CAddress},
+		{"ATECC608", atecc608.I2CBus, atecc608.I2CAddress},
+		{"TUSB320", tusb320.I2CBus, tusb320.I2CAddress},
+	}
+
+	for _, d := range devices {
+		if d.bus == bus && d.addr == addr {
+			return d.name
+		}
+	}
+
+	return ""
+}
+
+func parseArgs(args []string, bits ...int) (vals []int, err error) {
+	for i, arg := range args {
+		v, err := strconv.ParseUint(arg, 0, bits[i])
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument %s", arg)
+		}
+
+		vals = append(vals, int(v))
+	}
+
+	return
+}
+
+// i2cScan lists the addresses acknowledging a read-only transaction, the
+// ATECC608 is woken up beforehand as it does not answer while asleep.
+func i2cScan(bus int) (res string, err error) {
+	h, err := i2c.Open(bus)
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = h.Close() }() // make errcheck happy
+
+	if bus == atecc608.I2CBus {
+		d := atecc608.New(h, atecc608.I2CAddress)
+
+		if d.Wake() == nil {
+			defer d.Sleep()
+		}
+	}
+
+	var found []string
+
+	// skip reserved addresses, as i2cdetect does
+	for addr := 0x03; addr <= 0x77; addr++ {
+		if err := i2c.Probe(h, addr); err != nil {
+			continue
+		}
+
+		found = append(found, strings.TrimSpace(fmt.Sprintf("%#.2x %s", addr, knownDevice(bus, addr))))
+	}
+
+	return strings.Join(found, "\n"), nil
+}
+
+func i2cRead(args []string) (res string, err error) {
+	v, err := parseArgs(args, 8, 7, 8, 8)
+
+	if err != nil {
+		return
+	}
+
+	val, err := i2c.Read(v[0], v[1], uint8(v[2]), uint(v[3]))
+
+	if err != nil {
+		return
+	}
+
+	return fmt.Sprintf("%#x", val), nil
+}
+
+func i2cWrite(args []string) (err error) {
+	bits := []int{8, 7, 8}
+
+	for range args[3:] {
+		bits = append(bits, 8)
+	}
+
+	v, err := parseArgs(args, bits...)
+
+	if err != nil {
+		return
+	}
+
+	bus := v[0]
+	addr := v[1]
+	reg := uint8(v[2])
+
+	var val []byte
+
+	for _, b := range v[3:] {
+		val = append(val, byte(b))
+	}
+
+	if name := knownDevice(bus, addr); name != "" {
+		fmt.Fprintf(os.Stderr, "warning: writing to %s (bus %d, address %#x)\n", name, bus, addr)
+	}
+
+	return i2c.Write(bus, addr, reg, val)
+}
+
+func probeTUSB320() (details string, err error) {
+	id, err := tusb320.GetDeviceID()
+
+	if err != nil {
+		return
+	}
+
+	details = strings.Trim(string(id), "\x00")
+
+	if details != "TUSB320" {
+		err = fmt.Errorf("unexpected identifier %q", details)
+	}
+
+	return
+}
+
+func probeFUSB303() (details string, err error) {
+	id, err := fusb303.GetDeviceID()
+
+	if err != nil {
+		return
+	}
+
+	details = fmt.Sprintf("id:%#x", id)
+
+	// VERSION_ID (FUSB303/D, Table 13)
+	if id[0]>>4 != 0x1 {
+		err = fmt.Errorf("unexpected device ID %#x", id)
+	}
+
+	return
+}
+
+func probePF1510() (details string, err error) {
+	h, err := i2c.Open(pf1510.I2CBus)
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = h.Close() }() // make errcheck happy
+
+	// DEVICE_ID register (p53, Table 52, PF1510 Datasheet)
+	reg, err := pf1510.Registers.Register("DEVICE_ID")
+
+	if err != nil {
+		return
+	}
+
+	val, err := reg.Read(h, pf1510.I2CAddress)
+
+	if err != nil {
+		return
+	}
+
+	details = reg.Decode(val)
+
+	idField, err := reg.Field("DEVICE_ID")
+
+	if err != nil {
+		return
+	}
+
+	familyField, err := reg.Field("FAMILY")
+
+	if err != nil {
+		return
+	}
+
+	id := idField.Decode(val[0])
+	family := familyField.Decode(val[0])
+
+	if id != 0x4 || family != 15 {
+		err = fmt.Errorf("unexpected device ID %#x or family %d", id, family)
+	}
+
+	return
+}
+
+func probeATECC608() (details string, err error) {
+	// Info command, param1 0x00: returns the device revision
+	// (p86, 11.6 Info Command, ATECC608A Full Datasheet).
+	rev, err := atecc608.ExecuteCmd(atecc608.Cmd["Info"], [1]byte{0x00}, [2]byte{0x00, 0x00}, nil, true)
+
+	if err != nil {
+		return
+	}
+
+	details = fmt.Sprintf("revision:%#x", rev)
+
+	if len(rev) != 4 || rev[2] != 0x60 {
+		err = errors.New("unexpected revision")
+	}
+
+	return
+}
+
+// binaryFormat returns the output format argument (hex when missing).
+func binaryFormat(args []string) (format string, err error) {
+	format = "hex"
+
+	if len(args) > 0 {
+		format = args[0]
+	}
+
+	switch format {
+	case "hex", "base64", "raw":
+	default:
+		err = fmt.Errorf("invalid output format %s", format)
+	}
+
+	return
+}
+
+// encodeBinary returns buf hex or base64 encoded, raw output is written to
+// stdout.
+func encodeBinary(buf []byte, format string) (res string, err error) {
+	switch format {
+	case "hex":
+		res = hex.EncodeToString(buf)
+	case "base64":
+		res = base64.StdEncoding.EncodeToString(buf)
+	case "raw":
+		_, err = os.Stdout.Write(buf)
+	}
+
+	return
+}
+
+// ateccRandom reads random data from the ATECC608 random number generator.
+func ateccRandom(args []string) (res string, err error) {
+	format, err := binaryFormat(args[1:])
+
+	if err != nil {
+		return
+	}
+
+	n, err := parseArgs(args[:1], 16)
+
+	if err != nil {
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	buf := make([]byte, n[0])
+
+	if err = atecc608.ReadRandomContext(ctx, buf); err != nil {
+		return
+	}
+
+	return encodeBinary(buf, format)
+}
+
+// ateccRead reads an ATECC608 data zone slot, only slots readable in clear
+// text are allowed.
+func ateccRead(args []string) (res string, err error) {
+	format, err := binaryFormat(args[1:])
+
+	if err != nil {
+		return
+	}
+
+	slot, err := parseArgs(args[:1], 4)
+
+	if err != nil {
+		return
+	}
+
+	c, err := atecc608.GetConfig()
+
+	if err != nil {
+		return
+	}
+
+	if !c.DataLocked {
+		return "", atecc608.ErrZoneUnlocked
+	}
+
+	if info := c.SlotInfo(slot[0]); info.Read != atecc608.AccessClear {
+		return "", fmt.Errorf("slot %d is not readable in clear text (%s, read access: %s)", slot[0], info.KeyType, info.Read)
+	}
+
+	buf, err := atecc608.ReadSlot(atecc608.ZoneData, slot[0])
+
+	if err != nil {
+		return
+	}
+
+	return encodeBinary(buf, format)
+}
+
+// ateccRNGD feeds the kernel entropy pool until interrupted.
+func ateccRNGD() (err error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// report errors on stderr, even when not debugging
+	atecc608.RNGD.Report = func(err error) {
+		fmt.Fprintf(os.Stderr, "rngd: %v\n", err)
+	}
+
+	return atecc608.FeedEntropy(ctx)
+}
+
+// ateccConfig returns the ATECC608 configuration zone decoded as table or,
+// when requested, as JSON.
+func ateccConfig(args []string) (res string, err error) {
+	var buf bytes.Buffer
+
+	if len(args) > 0 && args[0] != "json" {
+		return "", fmt.Errorf("invalid output format %s", args[0])
+	}
+
+	c, err := atecc608.GetConfig()
+
+	if err != nil {
+		return
+	}
+
+	if len(args) > 0 {
+		j, err := json.MarshalIndent(c, "", "  ")
+		return string(j), err
+	}
+
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(w, "serial\t%s\n", c.Serial)
+	fmt.Fprintf(w, "revision\t%s\n", c.Revision)
+	fmt.Fprintf(w, "aes_enable\t%v\n", c.AESEnable)
+	fmt.Fprintf(w, "i2c_enable\t%v\n", c.I2CEnable)
+	fmt.Fprintf(w, "i2c_address\t%#x\n", c.I2CAddress)
+	fmt.Fprintf(w, "count_match\tenable:%v key:%d\n", c.CountMatchEnable, c.CountMatchKey)
+	fmt.Fprintf(w, "chip_mode\tuser_extra_add:%v ttl_enable:%v watchdog:%s clock_divider:%#x\n",
+		c.ChipMode.UserExtraAdd, c.ChipMode.TTLEnable, c.ChipMode.Watchdog, c.ChipMode.ClockDivider)
+	fmt.Fprintf(w, "counters\t%s %s\n", c.Counters[0], c.Counters[1])
+	fmt.Fprintf(w, "use_lock\tenable:%v key:%d\n", c.UseLockEnable, c.UseLockKey)
+	fmt.Fprintf(w, "volatile_key_permission\tenable:%v slot:%d\n", c.VolatileKeyPermissionEnable, c.VolatileKeyPermissionSlot)
+	fmt.Fprintf(w, "secure_boot\tmode:%d persistent:%v rand_nonce:%v sig_dig:%d pub_key:%d\n",
+		c.SecureBoot.Mode, c.SecureBoot.Persistent, c.SecureBoot.RandNonce, c.SecureBoot.SigDig, c.SecureBoot.PubKey)
+	fmt.Fprintf(w, "kdf_iv\tloc:%d str:%s\n", c.KdfIvLoc, c.KdfIvStr)
+	fmt.Fprintf(w, "user_extra\t%#x add:%#x\n", c.UserExtra, c.UserExtraAdd)
+	fmt.Fprintf(w, "chip_options\tpower_on_self_test:%v io_protection_key_enable:%v kdf_aes_enable:%v ecdh_protection:%d kdf_protection:%d io_protection_key:%d\n",
+		c.ChipOptions.PowerOnSelfTest, c.ChipOptions.IOProtectionKeyEnable, c.ChipOptions.KDFAESEnable,
+		c.ChipOptions.ECDHProtection, c.ChipOptions.KDFProtection, c.ChipOptions.IOProtectionKey)
+
+	for i, x := range c.X509Format {
+		fmt.Fprintf(w, "x509_format[%d]\tpublic_position:%d template_length:%d\n", i, x.PublicPosition, x.TemplateLength)
+	}
+
+	fmt.Fprintf(w, "data_locked\t%v\n", c.DataLocked)
+	fmt.Fprintf(w, "config_locked\t%v\n", c.ConfigLocked)
+
+	if err = w.Flush(); err != nil {
+		return
+	}
+
+	buf.WriteString("\n")
+
+	w = tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "SLOT\tLOCKED\tSLOT_CONFIG\tREAD_KEY\tNO_MAC\tLIMITED_USE\tENCRYPT_READ\tIS_SECRET\tWRITE_KEY\tWRITE_CONFIG\t")
+	fmt.Fprintf(w, "KEY_CONFIG\tPRIVATE\tPUB_INFO\tKEY_TYPE\tLOCKABLE\tREQ_RANDOM\tREQ_AUTH\tAUTH_KEY\tPERSISTENT_DISABLE\tX509_ID\n")
+
+	for _, slot := range c.Slots {
+		sc := slot.SlotConfig
+		kc := slot.KeyConfig
+
+		fmt.Fprintf(w, "%d\t%v\t%#.4x\t%#x\t%v\t%v\t%v\t%v\t%d\t%#x\t", slot.Index, slot.Locked, slot.SlotConfigRaw,
+			sc.ReadKey, sc.NoMac, sc.LimitedUse, sc.EncryptRead, sc.IsSecret, sc.WriteKey, sc.WriteConfig)
+		fmt.Fprintf(w, "%#.4x\t%v\t%v\t%d\t%v\t%v\t%v\t%d\t%v\t%d\n", slot.KeyConfigRaw,
+			kc.Private, kc.PubInfo, kc.KeyType, kc.Lockable, kc.ReqRandom, kc.ReqAuth, kc.AuthKey, kc.PersistentDisable, kc.X509ID)
+	}
+
+	if err = w.Flush(); err != nil {
+		return
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// ateccSlots returns the ATECC608 key slots description as table or, when
+// requested, as JSON.
+func ateccSlots(args []string) (res string, err error) {
+	var buf bytes.Buffer
+
+	if len(args) > 0 && args[0] != "json" {
+		return "", fmt.Errorf("invalid output format %s", args[0])
+	}
+
+	slots, err := atecc608.SlotsInfo()
+
+	if err != nil {
+		return
+	}
+
+	if len(args) > 0 {
+		j, err := json.MarshalIndent(slots, "", "  ")
+		return string(j), err
+	}
+
+	slotRef := func(slot int) string {
+		if slot < 0 {
+			return "-"
+		}
+
+		return strconv.Itoa(slot)
+	}
+
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "SLOT\tTYPE\tSIZE\tLOCKED\tREAD\tWRITE\tENC_KEY\tSIGN\tECDH\tGENKEY\tLIMITED_USE\tCOUNT_MATCH\tAUTH_KEY\n")
+
+	for _, s := range slots {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%v\t%s\t%s\t%s\t%v\t%v\t%v\t%v\t%v\t%s\n",
+			s.Index, s.KeyType, s.Size, s.Locked, s.Read, s.Write, slotRef(s.EncryptionKey),
+			s.Sign, s.ECDH, s.GenKey, s.LimitedUse, s.CountMatch, slotRef(s.AuthKey))
+	}
+
+	if err = w.Flush(); err != nil {
+		return
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+func probeANNAB112() (details string, err error) {
+	// AT+CGMM response lines, excluding the final result code
+	lines, err := anna_b112.Command("+CGMM")
+
+	if err != nil {
+		return
+	}
+
+	for _, line := range lines {
+		if model := strings.Trim(strings.TrimSpace(line), `"`); model != "" {
+			details = model
+			break
+		}
+	}
+
+	if details != "ANNA-B112" {
+		err = errors.New("unexpected model")
+	}
+
+	return
+}
+
+// probe checks the identity of all on-board devices, printing a pass/fail
+// table, an error is returned if any check fails.
+func probe() (err error) {
+	checks := []struct {
+		name  string
+		check func() (string, error)
+	}{
+		{"TUSB320", probeTUSB320},
+		{"FUSB303", probeFUSB303},
+		{"PF1510", probePF1510},
+		{"ATECC608", probeATECC608},
+		{"ANNA-B112", probeANNAB112},
+	}
+
+	var buf bytes.Buffer
+	var failed int
+
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "DEVICE\tRESULT\tDETAILS\n")
+
+	for _, c := range checks {
+		result := "PASS"
+		details, err := c.check()
+
+		if err != nil {
+			result = "FAIL"
+			details = strings.TrimSpace(details + " " + err.Error())
+			failed++
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.name, result, details)
+	}
+
+	_ = w.Flush()
+	log.Print(buf.String())
+
+	if failed > 0 {
+		err = fmt.Errorf("probe failed (%d/%d devices)", failed, len(checks))
+	}
+
+	return
+}
+
 func invalid() {
 	flag.Usage()
 	log.Fatalf("error: invalid command given")
@@ -159,25 +795,41 @@ func main() {
 
 	flag.Parse()
 
-	if len(flag.Args()) < 2 {
+	if len(flag.Args()) < 1 {
 		flag.Usage()
 		err = errors.New("no valid command given")
 		return
 	}
 
+	if conf.replay != "" {
+		if err = replay(conf.replay); err != nil {
+			return
+		}
+
+		conf.force = true
+	}
+
+	if conf.record != "" {
+		if err = record(conf.record); err != nil {
+			return
+		}
+	}
+
 	if !conf.force && !checkModel() {
 		err = errors.New("this tool is only meant to be used on USB armory Mk II hardware")
 		return
 	}
 
-	if conf.debug {
-		armoryctl.Logger = conf.logger
+	if conf.debug || conf.trace {
+		if err = setLogger(); err != nil {
+			return
+		}
 	}
 
 	device := flag.Arg(0)
 	command := flag.Arg(1)
 
-	op := fmt.Sprintf("%s %s", device, command)
+	op := strings.TrimSpace(fmt.Sprintf("%s %s", device, command))
 
 	switch op {
 	case "led white", "led blue":
@@ -221,6 +873,10 @@ func main() {
 		if err == nil {
 			res = fusb303.CurrentMode[mode]
 		}
+	case "tusb dump":
+		res, err = tusb320.Dump()
+	case "fusb dump":
+		res, err = fusb303.Dump()
 	case "fusb enable":
 		err = fusb303.Enable()
 	case "fusb disable":
@@ -246,6 +902,7 @@ func main() {
 		case "at":
 			err = anna_b112.ATSetInternalRCLFCK()
 		case "flash":
+			anna_b112.OpenOCDOutput = printOpenOCD
 			err = anna_b112.FlashSetInternalRCLFCK()
 		default:
 			invalid()
@@ -255,6 +912,7 @@ func main() {
 			invalid()
 		}
 
+		anna_b112.OpenOCDOutput = printOpenOCD
 		err = anna_b112.Update(flag.Arg(2))
 	case "ble name":
 		if len(flag.Args()) < 3 {
@@ -262,12 +920,58 @@ func main() {
 		}
 
 		err = anna_b112.SetDeviceName(flag.Arg(2))
+	case "ble monitor":
+		err = monitorBLE()
 	case "atecc info":
 		res, err = atecc608.Info()
 	case "atecc self_test":
 		res, err = atecc608.SelfTest()
+	case "atecc random":
+		if len(flag.Args()) < 3 {
+			invalid()
+		}
+
+		res, err = ateccRandom(flag.Args()[2:])
+	case "atecc rngd":
+		err = ateccRNGD()
+	case "atecc config":
+		res, err = ateccConfig(flag.Args()[2:])
+	case "atecc slots":
+		res, err = ateccSlots(flag.Args()[2:])
+	case "atecc read":
+		if len(flag.Args()) < 3 {
+			invalid()
+		}
+
+		res, err = ateccRead(flag.Args()[2:])
 	case "pmic info":
 		res, err = pf1510.Info()
+	case "pmic dump":
+		res, err = pf1510.Dump()
+	case "probe":
+		err = probe()
+	case "i2c scan":
+		if len(flag.Args()) < 3 {
+			invalid()
+		}
+
+		var bus []int
+
+		if bus, err = parseArgs(flag.Args()[2:3], 8); err == nil {
+			res, err = i2cScan(bus[0])
+		}
+	case "i2c read":
+		if len(flag.Args()) < 6 {
+			invalid()
+		}
+
+		res, err = i2cRead(flag.Args()[2:6])
+	case "i2c write":
+		if len(flag.Args()) < 6 {
+			invalid()
+		}
+
+		err = i2cWrite(flag.Args()[2:])
 	default:
 		invalid()
 	}
@@ -16,12 +16,17 @@ package atecc608
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/usbarmory/armoryctl/internal"
 )
 
+// Default instance bus number and address, used by the package level
+// functions.
 var (
 	I2CBus     = 0
 	I2CAddress = 0x60
@@ -41,6 +46,10 @@ const CmdMaxExecutionTime = (200 + 50) * time.Millisecond
 // retrieval.
 var CmdExecutionTime = CmdMaxExecutionTime
 
+// Retry sets the command retry policy, the global one is used when nil (see
+// armoryctl.Retry).
+var Retry *armoryctl.RetryPolicy
+
 // Minimum required cmd fields:
 //   count (1) + op (1) + param1 (1) + param2 (2) + crc16 (2).
 const cmdMinLen = 7
@@ -76,6 +85,20 @@ var Cmd = map[string]byte{
 	"Write":       0x12,
 }
 
+// RetryableCmd represents the command codes which are safe to execute more
+// than once, only those are retried on transient errors (see Retry) as a
+// failed response does not imply that the command was not executed.
+//
+// Read, Info and SelfTest do not alter the device state. Random updates the
+// EEPROM RNG seed, unless param1 is 0x01, a repeated execution only results
+// in a further seed update.
+var RetryableCmd = map[byte]bool{
+	0x02: true, // Read
+	0x1B: true, // Random
+	0x30: true, // Info
+	0x77: true, // SelfTest
+}
+
 // Status represents the device status/error codes,
 // (p64-65, Tab 10-3, ATECC608A Full Datasheet).
 var Status = map[byte]string{
@@ -91,6 +114,36 @@ var Status = map[byte]string{
 	0xff: "CRC or other communications error",
 }
 
+// Errors returned on response verification and wake-up failures.
+var (
+	ErrChecksum   = armoryctl.ErrChecksum
+	ErrWakeFailed = errors.New("wake-up failed")
+)
+
+// StatusError is returned when the device responds with an error status code
+// (see Status), communication errors (0xff) match armoryctl.ErrChecksum while
+// all others match armoryctl.ErrDeviceStatus.
+type StatusError struct {
+	// Status is the status/error code.
+	Status byte
+}
+
+func (e *StatusError) Error() string {
+	if s, ok := Status[e.Status]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("invalid status/error code: %x", e.Status)
+}
+
+func (e *StatusError) Unwrap() error {
+	if e.Status == 0xff {
+		return armoryctl.ErrChecksum
+	}
+
+	return armoryctl.ErrDeviceStatus
+}
+
 // Supported tests and result bit mask,
 // (p100, Table 11-43, ATECC608A Full Datasheet).
 var testMask = map[string]byte{
@@ -101,6 +154,38 @@ var testMask = map[string]byte{
 	"DRBG":  0x01,
 }
 
+// Device represents an ATECC608A/ATECC608B secure element instance.
+type Device struct {
+	// I²C bus transport
+	Bus armoryctl.I2C
+	// I²C slave address
+	Address int
+}
+
+// New returns an ATECC608 secure element instance on the given bus and
+// address.
+func New(bus armoryctl.I2C, addr int) *Device {
+	return &Device{
+		Bus:     bus,
+		Address: addr,
+	}
+}
+
+func openDefault() (d *Device, bus armoryctl.I2CHandle, err error) {
+	bus, err = armoryctl.OpenI2C(I2CBus)
+
+	if err != nil {
+		return
+	}
+
+	return New(bus, I2CAddress), bus, nil
+}
+
+// bus returns the device transport, logging transfers.
+func (d *Device) bus() armoryctl.I2C {
+	return armoryctl.WithLog(d.Bus, "ATECC608")
+}
+
 func crc16(data []byte) []byte {
 	var crc uint16
 
@@ -132,7 +217,7 @@ func verifyResponse(res []byte) (data []byte, err error) {
 	//
 	// (p63, Table 10-1, ATECC608A Full Datasheet)
 	if len(res) < responseMinLen {
-		err = fmt.Errorf("invalid response, got less than %d bytes", responseMinLen)
+		err = armoryctl.Classify(fmt.Errorf("invalid response, got less than %d bytes", responseMinLen), armoryctl.ErrChecksum)
 		return
 	}
 
@@ -144,7 +229,7 @@ func verifyResponse(res []byte) (data []byte, err error) {
 	crc := res[size:]
 
 	if !bytes.Equal(crc16(payload), crc) {
-		err = fmt.Errorf("checksum verification failure")
+		err = ErrChecksum
 		return
 	}
 
@@ -156,10 +241,8 @@ func verifyResponse(res []byte) (data []byte, err error) {
 
 	status := data[0]
 
-	if Status[status] == "" {
-		err = fmt.Errorf("invalid status/error code: %x", status)
-	} else if status != 0x00 && (status <= 0x0f || status == 0xff) {
-		err = fmt.Errorf("%s", Status[status])
+	if Status[status] == "" || (status != 0x00 && (status <= 0x0f || status == 0xff)) {
+		err = &StatusError{Status: status}
 	}
 
 	return
@@ -167,30 +250,39 @@ func verifyResponse(res []byte) (data []byte, err error) {
 
 // Wake issues a device wake-up which is always needed before starting a
 // new command session.
-func Wake() (err error) {
+func (d *Device) Wake() (err error) {
+	return d.WakeContext(context.Background())
+}
+
+// WakeContext is like Wake but the wake-up is aborted once ctx is done.
+func (d *Device) WakeContext(ctx context.Context) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
 	// Any error at the very first I2CWrite() is silently ignored as
 	// the device always returns a "Write Error" here.
 	//
 	// Writing 0x00 triggers the chip wake-up
 	// (p47, 7.1 I/O Conditions, ATECC608A Full Datasheet).
-	_ = armoryctl.I2CWrite(I2CBus, I2CAddress, 0x00, []byte{0x00})
+	_ = d.bus().Write(d.Address, 0x00, []byte{0x00})
 
 	// Wait tWHI
 	// (p56, 9.3 AC Parameters: All I/O Interfaces, ATECC608A Full Datasheet).
-	time.Sleep(1500 * time.Microsecond)
+	if err = armoryctl.Sleep(ctx, 1500*time.Microsecond); err != nil {
+		return
+	}
 
 	// It is necessary to read 4 bytes of data to verify that the chip
 	// wake-up has been successful.
-	res, err := armoryctl.I2CRead(I2CBus, I2CAddress, 0x00, 4)
+	res, err := d.bus().Read(d.Address, 0x00, 4)
 
 	if err != nil {
 		return
 	}
 
-	data, err := verifyResponse(res)
-
-	if err != nil && data[0] != 0x11 {
-		err = fmt.Errorf("wake-up failed")
+	if _, err = verifyResponse(res); err != nil {
+		err = fmt.Errorf("%w, %w", ErrWakeFailed, err)
 	}
 
 	return
@@ -198,14 +290,14 @@ func Wake() (err error) {
 
 // Idle puts the device in idle mode,
 // (p50, Table 7-2, ATECC608A Full Datasheet).
-func Idle() {
-	_ = armoryctl.I2CWrite(I2CBus, I2CAddress, 0x02, nil)
+func (d *Device) Idle() {
+	_ = d.bus().Write(d.Address, 0x02, nil)
 }
 
 // Sleep puts the device in sleep mode,
 // (p50, Table 7-2, ATECC608A Full Datasheet).
-func Sleep() {
-	_ = armoryctl.I2CWrite(I2CBus, I2CAddress, 0x01, nil)
+func (d *Device) Sleep() {
+	_ = d.bus().Write(d.Address, 0x01, nil)
 }
 
 // ExecuteCmd issues an ATECC command conforming to:
@@ -216,13 +308,45 @@ func Sleep() {
 // within a Wake() and Idle() cycle, when the flag is false the caller must
 // take care of waking/idling/sleeping according to its desired command
 // sequence.
-func ExecuteCmd(opcode byte, param1 [1]byte, param2 [2]byte, data []byte, wake bool) (res []byte, err error) {
+func (d *Device) ExecuteCmd(opcode byte, param1 [1]byte, param2 [2]byte, data []byte, wake bool) (res []byte, err error) {
+	return d.ExecuteCmdContext(context.Background(), opcode, param1, param2, data, wake)
+}
+
+// ExecuteCmdContext is like ExecuteCmd but the command is aborted once ctx is
+// done, including while waiting for its execution.
+//
+// Commands failing with transient errors are retried according to Retry,
+// only when listed in RetryableCmd.
+func (d *Device) ExecuteCmdContext(ctx context.Context, opcode byte, param1 [1]byte, param2 [2]byte, data []byte, wake bool) (res []byte, err error) {
+	start := time.Now()
+
+	if RetryableCmd[opcode] {
+		err = Retry.Do(ctx, func() (err error) {
+			res, err = d.executeCmd(ctx, opcode, param1, param2, data, wake)
+			return
+		})
+	} else {
+		res, err = d.executeCmd(ctx, opcode, param1, param2, data, wake)
+	}
+
+	attrs := []any{armoryctl.LogDevice, "ATECC608", armoryctl.LogAddress, d.Address, armoryctl.LogOpcode, opcode, armoryctl.LogDuration, time.Since(start)}
+
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+
+	armoryctl.Log(slog.LevelDebug, "command", attrs...)
+
+	return
+}
+
+func (d *Device) executeCmd(ctx context.Context, opcode byte, param1 [1]byte, param2 [2]byte, data []byte, wake bool) (res []byte, err error) {
 	if wake {
-		if err = Wake(); err != nil {
+		if err = d.WakeContext(ctx); err != nil {
 			return
 		}
 
-		defer Idle()
+		defer d.Idle()
 	}
 
 	// ATECC cmd packet format:
@@ -243,18 +367,20 @@ func ExecuteCmd(opcode byte, param1 [1]byte, param2 [2]byte, data []byte, wake b
 	pkt = append(pkt, data...)
 	pkt = append(pkt, crc16(pkt)...)
 
-	if err = armoryctl.I2CWrite(I2CBus, I2CAddress, CmdAddress, pkt); err != nil {
+	if err = d.bus().Write(d.Address, CmdAddress, pkt); err != nil {
 		return
 	}
 
-	time.Sleep(CmdExecutionTime)
+	if err = armoryctl.Sleep(ctx, CmdExecutionTime); err != nil {
+		return
+	}
 
 	// The output FIFO is shared among status, error, and command results.
 	// The first read command is needed to read how many bytes are present
 	// in the output buffer.
 	//
 	// (p64, 10.3 Status/Error Codes, ATECC608A Full Datasheet)
-	resCount, err := armoryctl.I2CRead(I2CBus, I2CAddress, CmdAddress, 1)
+	resCount, err := d.bus().Read(d.Address, CmdAddress, 1)
 
 	if err != nil {
 		return
@@ -262,7 +388,7 @@ func ExecuteCmd(opcode byte, param1 [1]byte, param2 [2]byte, data []byte, wake b
 
 	// The second read command gets the rest of the response from the
 	// output buffer.
-	res, err = armoryctl.I2CRead(I2CBus, I2CAddress, CmdAddress, uint(resCount[0]))
+	res, err = d.bus().Read(d.Address, CmdAddress, uint(resCount[0]))
 
 	if err != nil {
 		return
@@ -272,9 +398,15 @@ func ExecuteCmd(opcode byte, param1 [1]byte, param2 [2]byte, data []byte, wake b
 }
 
 // SelfTest executes the self test command and returns its results.
-func SelfTest() (res string, err error) {
+func (d *Device) SelfTest() (res string, err error) {
+	return d.SelfTestContext(context.Background())
+}
+
+// SelfTestContext is like SelfTest but the command is aborted once ctx is
+// done.
+func (d *Device) SelfTestContext(ctx context.Context) (res string, err error) {
 	// param1 0x3b: performs all available tests.
-	data, err := ExecuteCmd(Cmd["SelfTest"], [1]byte{0x3b}, [2]byte{0x00, 0x00}, nil, true)
+	data, err := d.ExecuteCmdContext(ctx, Cmd["SelfTest"], [1]byte{0x3b}, [2]byte{0x00, 0x00}, nil, true)
 
 	if err != nil {
 		return
@@ -293,10 +425,14 @@ func SelfTest() (res string, err error) {
 
 // Info executes the info command and returns the device serial number and
 // software revision.
-func Info() (res string, err error) {
-	// param1 0x80: reads 32 bytes configuration region
-	// param2 0x0000: represents the start address
-	data, err := ExecuteCmd(Cmd["Read"], [1]byte{0x80}, [2]byte{0x00, 0x00}, nil, true)
+func (d *Device) Info() (res string, err error) {
+	return d.InfoContext(context.Background())
+}
+
+// InfoContext is like Info but the command is aborted once ctx is done.
+func (d *Device) InfoContext(ctx context.Context) (res string, err error) {
+	// reads 32 bytes from configuration zone block 0
+	data, err := d.readZone(ctx, ZoneConfig, 0, 0, 0, BlockSize)
 
 	if err != nil {
 		return
@@ -312,3 +448,100 @@ func Info() (res string, err error) {
 
 	return fmt.Sprintf("serial:0x%x revision:0x%x", serial, revision), nil
 }
+
+// Wake issues a device wake-up on the default instance (see Device.Wake).
+func Wake() (err error) {
+	return WakeContext(context.Background())
+}
+
+// WakeContext is like Wake but the wake-up is aborted once ctx is done.
+func WakeContext(ctx context.Context) (err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.WakeContext(ctx)
+}
+
+// Idle puts the default instance in idle mode (see Device.Idle).
+func Idle() {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	d.Idle()
+}
+
+// Sleep puts the default instance in sleep mode (see Device.Sleep).
+func Sleep() {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	d.Sleep()
+}
+
+// ExecuteCmd issues an ATECC command on the default instance (see
+// Device.ExecuteCmd).
+func ExecuteCmd(opcode byte, param1 [1]byte, param2 [2]byte, data []byte, wake bool) (res []byte, err error) {
+	return ExecuteCmdContext(context.Background(), opcode, param1, param2, data, wake)
+}
+
+// ExecuteCmdContext is like ExecuteCmd but the command is aborted once ctx is
+// done.
+func ExecuteCmdContext(ctx context.Context, opcode byte, param1 [1]byte, param2 [2]byte, data []byte, wake bool) (res []byte, err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.ExecuteCmdContext(ctx, opcode, param1, param2, data, wake)
+}
+
+// SelfTest executes the self test command on the default instance (see
+// Device.SelfTest).
+func SelfTest() (res string, err error) {
+	return SelfTestContext(context.Background())
+}
+
+// SelfTestContext is like SelfTest but the command is aborted once ctx is
+// done.
+func SelfTestContext(ctx context.Context) (res string, err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.SelfTestContext(ctx)
+}
+
+// Info returns the default instance serial number and software revision (see
+// Device.Info).
+func Info() (res string, err error) {
+	return InfoContext(context.Background())
+}
+
+// InfoContext is like Info but the command is aborted once ctx is done.
+func InfoContext(ctx context.Context) (res string, err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.InfoContext(ctx)
+}
@@ -0,0 +1,148 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package atecc608_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/usbarmory/armoryctl/atecc608"
+	"github.com/usbarmory/armoryctl/sim"
+)
+
+func setup(t *testing.T) (dev *sim.ATECC608) {
+	bus := sim.NewBus()
+	dev = sim.NewATECC608()
+
+	bus.Attach(atecc608.I2CAddress, dev)
+	sim.Install(t, bus)
+
+	// the simulated device executes commands immediately
+	execTime := atecc608.CmdExecutionTime
+	atecc608.CmdExecutionTime = 0
+	t.Cleanup(func() { atecc608.CmdExecutionTime = execTime })
+
+	return
+}
+
+func TestInfo(t *testing.T) {
+	setup(t)
+
+	res, err := atecc608.Info()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "serial:0x01236d2a5c1f893bee revision:0x00006003"; res != want {
+		t.Errorf("unexpected info %s, want %s", res, want)
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	setup(t)
+
+	res, err := atecc608.SelfTest()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(res, "FAIL") || strings.Count(res, "PASS") != 5 {
+		t.Errorf("unexpected self test result %s", res)
+	}
+}
+
+func TestRandom(t *testing.T) {
+	dev := setup(t)
+
+	src := make([]byte, 2*atecc608.RandomSize)
+
+	for i := range src {
+		src[i] = byte(i)
+	}
+
+	// locked configuration zone
+	dev.Config[87] = 0x00
+	dev.Rand = bytes.NewReader(src)
+
+	buf := make([]byte, len(src))
+
+	if err := atecc608.ReadRandom(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf, src) {
+		t.Errorf("unexpected random data %x", buf)
+	}
+}
+
+func TestRandomUnlocked(t *testing.T) {
+	setup(t)
+
+	buf := make([]byte, atecc608.RandomSize)
+
+	if err := atecc608.ReadRandom(buf); !errors.Is(err, atecc608.ErrRandomFailure) {
+		t.Errorf("expected random failure, got %v", err)
+	}
+}
+
+func TestReadSlot(t *testing.T) {
+	dev := setup(t)
+
+	for i := range dev.Data[8] {
+		dev.Data[8][i] = byte(i)
+	}
+
+	if _, err := atecc608.ReadSlot(atecc608.ZoneData, 8); !errors.Is(err, atecc608.ErrZoneUnlocked) {
+		t.Fatalf("expected unlocked zone error, got %v", err)
+	}
+
+	// locked data zone
+	dev.Config[86] = 0x00
+
+	res, err := atecc608.ReadSlot(atecc608.ZoneData, 8)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(res, dev.Data[8]) {
+		t.Errorf("unexpected slot data %x", res)
+	}
+
+	// slots 9-15 size is not a multiple of the block size
+	copy(dev.Data[9], "slot 9 content")
+
+	if res, err = atecc608.ReadSlot(atecc608.ZoneData, 9); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(res, dev.Data[9]) {
+		t.Errorf("unexpected slot data %x", res)
+	}
+}
+
+func TestReadZoneBounds(t *testing.T) {
+	dev := setup(t)
+
+	// locked data zone
+	dev.Config[86] = 0x00
+
+	// slot 0 is 36 bytes long
+	if _, err := atecc608.ReadZone(atecc608.ZoneData, 0, 1, 0, atecc608.BlockSize); err == nil {
+		t.Error("expected out of bounds error")
+	}
+
+	if _, err := atecc608.ReadZone(atecc608.ZoneData, 0, 1, 0, atecc608.WordSize); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,393 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package atecc608
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// ConfigSize is the configuration zone size in bytes.
+const ConfigSize = 128
+
+// Number of key slots.
+const Slots = 16
+
+// Lock byte value of unlocked zones.
+const unlocked = 0x55
+
+// HexBytes represents binary data, hex encoded in text and JSON output.
+type HexBytes []byte
+
+func (b HexBytes) String() string {
+	return fmt.Sprintf("%#x", []byte(b))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (b HexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+// SlotConfig represents the decoded SlotConfig of a key slot
+// (SlotConfig, ATECC608A Full Datasheet).
+type SlotConfig struct {
+	// ReadKey is the slot used for encrypted reads, or the usage
+	// restrictions for private keys.
+	ReadKey int `json:"read_key"`
+	// NoMac prohibits the key use by the MAC command.
+	NoMac bool `json:"no_mac"`
+	// LimitedUse limits the key use according to Counter[0].
+	LimitedUse bool `json:"limited_use"`
+	// EncryptRead requires reads to be encrypted.
+	EncryptRead bool `json:"encrypt_read"`
+	// IsSecret prohibits clear text reads and writes.
+	IsSecret bool `json:"is_secret"`
+	// WriteKey is the slot used for encrypted writes.
+	WriteKey int `json:"write_key"`
+	// WriteConfig controls the Write, DeriveKey, GenKey and PrivWrite
+	// commands.
+	WriteConfig int `json:"write_config"`
+}
+
+// KeyConfig represents the decoded KeyConfig of a key slot
+// (KeyConfig, ATECC608A Full Datasheet).
+type KeyConfig struct {
+	// Private denotes an ECC private key.
+	Private bool `json:"private"`
+	// PubInfo allows the public key generation, or controls the public
+	// key use for public key slots.
+	PubInfo bool `json:"pub_info"`
+	// KeyType is the key type (4: P256, 6: AES, 7: SHA or other data).
+	KeyType int `json:"key_type"`
+	// Lockable allows the individual slot locking.
+	Lockable bool `json:"lockable"`
+	// ReqRandom requires a random nonce.
+	ReqRandom bool `json:"req_random"`
+	// ReqAuth requires prior authorization with AuthKey.
+	ReqAuth bool `json:"req_auth"`
+	// AuthKey is the authorizing slot when ReqAuth is set.
+	AuthKey int `json:"auth_key"`
+	// PersistentDisable requires the persistent latch to be set.
+	PersistentDisable bool `json:"persistent_disable"`
+	// X509ID is the X509format entry used for certificate validation.
+	X509ID int `json:"x509_id"`
+}
+
+// Slot represents the configuration of a key slot.
+type Slot struct {
+	// Slot number
+	Index int `json:"index"`
+	// Locked is true when the slot is individually locked.
+	Locked bool `json:"locked"`
+	// Raw SlotConfig value
+	SlotConfigRaw uint16 `json:"slot_config_raw"`
+	// Decoded SlotConfig value
+	SlotConfig SlotConfig `json:"slot_config"`
+	// Raw KeyConfig value
+	KeyConfigRaw uint16 `json:"key_config_raw"`
+	// Decoded KeyConfig value
+	KeyConfig KeyConfig `json:"key_config"`
+}
+
+// ChipMode represents the decoded ChipMode byte.
+type ChipMode struct {
+	// UserExtraAdd replaces the I²C address when set by UpdateExtra.
+	UserExtraAdd bool `json:"user_extra_add"`
+	// TTLEnable sets TTL input levels.
+	TTLEnable bool `json:"ttl_enable"`
+	// Watchdog is the watchdog timeout (1.3s or 10s).
+	Watchdog string `json:"watchdog"`
+	// ClockDivider is the clock divider value.
+	ClockDivider int `json:"clock_divider"`
+}
+
+// SecureBoot represents the decoded SecureBoot configuration.
+type SecureBoot struct {
+	// Mode is the secure boot mode (0: disabled).
+	Mode int `json:"mode"`
+	// Persistent enables the persistent latch.
+	Persistent bool `json:"persistent"`
+	// RandNonce requires a random nonce.
+	RandNonce bool `json:"rand_nonce"`
+	// SigDig is the slot holding the signature or digest.
+	SigDig int `json:"sig_dig"`
+	// PubKey is the slot holding the public key.
+	PubKey int `json:"pub_key"`
+}
+
+// ChipOptions represents the decoded ChipOptions configuration.
+type ChipOptions struct {
+	// PowerOnSelfTest runs the self test at power-up.
+	PowerOnSelfTest bool `json:"power_on_self_test"`
+	// IOProtectionKeyEnable enables the I/O protection key.
+	IOProtectionKeyEnable bool `json:"io_protection_key_enable"`
+	// KDFAESEnable enables the KDF AES mode.
+	KDFAESEnable bool `json:"kdf_aes_enable"`
+	// ECDHProtection controls the ECDH output protection.
+	ECDHProtection int `json:"ecdh_protection"`
+	// KDFProtection controls the KDF output protection.
+	KDFProtection int `json:"kdf_protection"`
+	// IOProtectionKey is the I/O protection key slot.
+	IOProtectionKey int `json:"io_protection_key"`
+}
+
+// X509Format represents a decoded X509format entry.
+type X509Format struct {
+	// PublicPosition is the public key position in the certificate
+	// message.
+	PublicPosition int `json:"public_position"`
+	// TemplateLength is the certificate message template length.
+	TemplateLength int `json:"template_length"`
+}
+
+// Config represents the decoded configuration zone
+// (Configuration Zone, ATECC608A Full Datasheet).
+type Config struct {
+	// Serial number
+	Serial HexBytes `json:"serial"`
+	// Device revision
+	Revision HexBytes `json:"revision"`
+	// AES command enable
+	AESEnable bool `json:"aes_enable"`
+	// I²C interface enable
+	I2CEnable bool `json:"i2c_enable"`
+	// 7-bit I²C address
+	I2CAddress int `json:"i2c_address"`
+	// CountMatch enable
+	CountMatchEnable bool `json:"count_match_enable"`
+	// CountMatch key slot
+	CountMatchKey int `json:"count_match_key"`
+	// Decoded ChipMode
+	ChipMode ChipMode `json:"chip_mode"`
+	// Monotonic counters, in their native encoding
+	Counters [2]HexBytes `json:"counters"`
+	// UseLock enable
+	UseLockEnable bool `json:"use_lock_enable"`
+	// UseLock key slot
+	UseLockKey int `json:"use_lock_key"`
+	// VolatileKeyPermission enable
+	VolatileKeyPermissionEnable bool `json:"volatile_key_permission_enable"`
+	// VolatileKeyPermission slot
+	VolatileKeyPermissionSlot int `json:"volatile_key_permission_slot"`
+	// Decoded SecureBoot
+	SecureBoot SecureBoot `json:"secure_boot"`
+	// KDF IV location
+	KdfIvLoc int `json:"kdf_iv_loc"`
+	// KDF IV string
+	KdfIvStr HexBytes `json:"kdf_iv_str"`
+	// UserExtra byte
+	UserExtra int `json:"user_extra"`
+	// UserExtraAdd byte
+	UserExtraAdd int `json:"user_extra_add"`
+	// Data and OTP zones lock state (LockValue)
+	DataLocked bool `json:"data_locked"`
+	// Configuration zone lock state (LockConfig)
+	ConfigLocked bool `json:"config_locked"`
+	// Decoded ChipOptions
+	ChipOptions ChipOptions `json:"chip_options"`
+	// Decoded X509format entries
+	X509Format [4]X509Format `json:"x509_format"`
+	// Key slots configuration
+	Slots [Slots]Slot `json:"slots"`
+}
+
+func bit(v uint16, n uint) bool {
+	return v>>n&1 == 1
+}
+
+func bits(v uint16, shift uint, width uint) int {
+	return int(v>>shift) & (1<<width - 1)
+}
+
+// DecodeSlotConfig decodes a SlotConfig value.
+func DecodeSlotConfig(v uint16) SlotConfig {
+	return SlotConfig{
+		ReadKey:     bits(v, 0, 4),
+		NoMac:       bit(v, 4),
+		LimitedUse:  bit(v, 5),
+		EncryptRead: bit(v, 6),
+		IsSecret:    bit(v, 7),
+		WriteKey:    bits(v, 8, 4),
+		WriteConfig: bits(v, 12, 4),
+	}
+}
+
+// DecodeKeyConfig decodes a KeyConfig value.
+func DecodeKeyConfig(v uint16) KeyConfig {
+	return KeyConfig{
+		Private:           bit(v, 0),
+		PubInfo:           bit(v, 1),
+		KeyType:           bits(v, 2, 3),
+		Lockable:          bit(v, 5),
+		ReqRandom:         bit(v, 6),
+		ReqAuth:           bit(v, 7),
+		AuthKey:           bits(v, 8, 4),
+		PersistentDisable: bit(v, 12),
+		X509ID:            bits(v, 14, 2),
+	}
+}
+
+// DecodeConfig decodes the 128 bytes configuration zone.
+func DecodeConfig(buf []byte) (c *Config, err error) {
+	if len(buf) != ConfigSize {
+		return nil, fmt.Errorf("invalid configuration zone size (%d)", len(buf))
+	}
+
+	le := binary.LittleEndian
+
+	chipMode := uint16(buf[19])
+	useLock := uint16(buf[68])
+	volatileKey := uint16(buf[69])
+	secureBoot := le.Uint16(buf[70:])
+	chipOptions := le.Uint16(buf[90:])
+	slotLocked := le.Uint16(buf[88:])
+
+	c = &Config{
+		Serial:                      append(HexBytes{}, append(buf[0:4:4], buf[8:13]...)...),
+		Revision:                    append(HexBytes{}, buf[4:8]...),
+		AESEnable:                   buf[13]&1 == 1,
+		I2CEnable:                   buf[14]&1 == 1,
+		I2CAddress:                  int(buf[16] >> 1),
+		CountMatchEnable:            buf[18]&1 == 1,
+		CountMatchKey:               int(buf[18] >> 4),
+		UseLockEnable:               bits(useLock, 0, 4) == 0x0a,
+		UseLockKey:                  bits(useLock, 4, 4),
+		VolatileKeyPermissionEnable: bit(volatileKey, 7),
+		VolatileKeyPermissionSlot:   bits(volatileKey, 0, 4),
+		KdfIvLoc:                    int(buf[72]),
+		KdfIvStr:                    append(HexBytes{}, buf[73:75]...),
+		UserExtra:                   int(buf[84]),
+		UserExtraAdd:                int(buf[85]),
+		DataLocked:                  buf[86] != unlocked,
+		ConfigLocked:                buf[87] != unlocked,
+	}
+
+	c.ChipMode = ChipMode{
+		UserExtraAdd: bit(chipMode, 0),
+		TTLEnable:    bit(chipMode, 1),
+		Watchdog:     "1.3s",
+		ClockDivider: bits(chipMode, 3, 5),
+	}
+
+	if bit(chipMode, 2) {
+		c.ChipMode.Watchdog = "10s"
+	}
+
+	c.SecureBoot = SecureBoot{
+		Mode:       bits(secureBoot, 0, 2),
+		Persistent: bit(secureBoot, 3),
+		RandNonce:  bit(secureBoot, 4),
+		SigDig:     bits(secureBoot, 8, 4),
+		PubKey:     bits(secureBoot, 12, 4),
+	}
+
+	c.ChipOptions = ChipOptions{
+		PowerOnSelfTest:       bit(chipOptions, 0),
+		IOProtectionKeyEnable: bit(chipOptions, 1),
+		KDFAESEnable:          bit(chipOptions, 2),
+		ECDHProtection:        bits(chipOptions, 8, 2),
+		KDFProtection:         bits(chipOptions, 10, 2),
+		IOProtectionKey:       bits(chipOptions, 12, 4),
+	}
+
+	for i := range c.Counters {
+		c.Counters[i] = append(HexBytes{}, buf[52+i*8:60+i*8]...)
+	}
+
+	for i := range c.X509Format {
+		c.X509Format[i] = X509Format{
+			PublicPosition: int(buf[92+i] & 0x0f),
+			TemplateLength: int(buf[92+i] >> 4),
+		}
+	}
+
+	for i := range c.Slots {
+		slotConfig := le.Uint16(buf[20+i*2:])
+		keyConfig := le.Uint16(buf[96+i*2:])
+
+		c.Slots[i] = Slot{
+			Index: i,
+			// SlotLocked bits are cleared on locked slots
+			Locked:        !bit(slotLocked, uint(i)),
+			SlotConfigRaw: slotConfig,
+			SlotConfig:    DecodeSlotConfig(slotConfig),
+			KeyConfigRaw:  keyConfig,
+			KeyConfig:     DecodeKeyConfig(keyConfig),
+		}
+	}
+
+	return
+}
+
+// ReadConfig reads the 128 bytes configuration zone.
+func (d *Device) ReadConfig() (buf []byte, err error) {
+	return d.ReadConfigContext(context.Background())
+}
+
+// ReadConfigContext is like ReadConfig but the command is aborted once ctx
+// is done.
+func (d *Device) ReadConfigContext(ctx context.Context) (buf []byte, err error) {
+	return d.ReadSlotContext(ctx, ZoneConfig, 0)
+}
+
+// Config reads and decodes the configuration zone.
+func (d *Device) Config() (c *Config, err error) {
+	return d.ConfigContext(context.Background())
+}
+
+// ConfigContext is like Config but the command is aborted once ctx is done.
+func (d *Device) ConfigContext(ctx context.Context) (c *Config, err error) {
+	buf, err := d.ReadConfigContext(ctx)
+
+	if err != nil {
+		return
+	}
+
+	return DecodeConfig(buf)
+}
+
+// ReadConfig reads the configuration zone of the default instance (see
+// Device.ReadConfig).
+func ReadConfig() (buf []byte, err error) {
+	return ReadConfigContext(context.Background())
+}
+
+// ReadConfigContext is like ReadConfig but the command is aborted once ctx
+// is done.
+func ReadConfigContext(ctx context.Context) (buf []byte, err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.ReadConfigContext(ctx)
+}
+
+// GetConfig reads and decodes the configuration zone of the default instance
+// (see Device.Config).
+func GetConfig() (c *Config, err error) {
+	return GetConfigContext(context.Background())
+}
+
+// GetConfigContext is like GetConfig but the command is aborted once ctx is
+// done.
+func GetConfigContext(ctx context.Context) (c *Config, err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.ConfigContext(ctx)
+}
@@ -0,0 +1,101 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package atecc608_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/usbarmory/armoryctl/atecc608"
+)
+
+func TestDecodeConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		off  int
+		val  []byte
+		got  func(c *atecc608.Config) any
+		want any
+	}{
+		{"Serial[0:4]", 0, []byte{1, 2, 3, 4}, func(c *atecc608.Config) any { return []byte(c.Serial[0:4]) }, []byte{1, 2, 3, 4}},
+		{"Revision", 4, []byte{0, 0, 0x60, 0x03}, func(c *atecc608.Config) any { return []byte(c.Revision) }, []byte{0, 0, 0x60, 0x03}},
+		{"Serial[4:9]", 8, []byte{5, 6, 7, 8, 9}, func(c *atecc608.Config) any { return []byte(c.Serial[4:9]) }, []byte{5, 6, 7, 8, 9}},
+		{"AESEnable", 13, []byte{0x01}, func(c *atecc608.Config) any { return c.AESEnable }, true},
+		{"I2CEnable", 14, []byte{0x01}, func(c *atecc608.Config) any { return c.I2CEnable }, true},
+		{"I2CAddress", 16, []byte{0xc0}, func(c *atecc608.Config) any { return c.I2CAddress }, 0x60},
+		{"CountMatch", 18, []byte{0x51}, func(c *atecc608.Config) any { return [2]any{c.CountMatchEnable, c.CountMatchKey} }, [2]any{true, 5}},
+		{"ChipMode", 19, []byte{0x0d<<3 | 0x05}, func(c *atecc608.Config) any { return c.ChipMode }, atecc608.ChipMode{UserExtraAdd: true, Watchdog: "10s", ClockDivider: 0x0d}},
+		{"SlotConfig[0]", 20, []byte{0x20, 0x87}, func(c *atecc608.Config) any { return c.Slots[0].SlotConfigRaw }, uint16(0x8720)},
+		{"SlotConfig[15]", 50, []byte{0x0f, 0x0f}, func(c *atecc608.Config) any { return c.Slots[15].SlotConfig.ReadKey }, 0xf},
+		{"Counter[0]", 52, []byte{1, 2, 3, 4, 5, 6, 7, 8}, func(c *atecc608.Config) any { return []byte(c.Counters[0]) }, []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"Counter[1]", 60, []byte{8, 7, 6, 5, 4, 3, 2, 1}, func(c *atecc608.Config) any { return []byte(c.Counters[1]) }, []byte{8, 7, 6, 5, 4, 3, 2, 1}},
+		{"UseLock", 68, []byte{0x3a}, func(c *atecc608.Config) any { return [2]any{c.UseLockEnable, c.UseLockKey} }, [2]any{true, 3}},
+		{"VolatileKeyPermission", 69, []byte{0x84}, func(c *atecc608.Config) any {
+			return [2]any{c.VolatileKeyPermissionEnable, c.VolatileKeyPermissionSlot}
+		}, [2]any{true, 4}},
+		{"SecureBoot", 70, []byte{0x19, 0xab}, func(c *atecc608.Config) any { return c.SecureBoot }, atecc608.SecureBoot{Mode: 1, Persistent: true, RandNonce: true, SigDig: 0xb, PubKey: 0xa}},
+		{"KdfIv", 72, []byte{0x10, 0xaa, 0xbb}, func(c *atecc608.Config) any { return [2]any{c.KdfIvLoc, []byte(c.KdfIvStr)} }, [2]any{0x10, []byte{0xaa, 0xbb}}},
+		{"UserExtra", 84, []byte{0x11, 0x22}, func(c *atecc608.Config) any { return [2]any{c.UserExtra, c.UserExtraAdd} }, [2]any{0x11, 0x22}},
+		{"LockValue", 86, []byte{0x55, 0x00}, func(c *atecc608.Config) any { return [2]any{c.DataLocked, c.ConfigLocked} }, [2]any{false, true}},
+		{"LockConfig", 86, []byte{0x00, 0x55}, func(c *atecc608.Config) any { return [2]any{c.DataLocked, c.ConfigLocked} }, [2]any{true, false}},
+		{"SlotLocked", 88, []byte{0xfe, 0x7f}, func(c *atecc608.Config) any { return [3]bool{c.Slots[0].Locked, c.Slots[1].Locked, c.Slots[15].Locked} }, [3]bool{true, false, true}},
+		{"ChipOptions", 90, []byte{0x05, 0x96}, func(c *atecc608.Config) any { return c.ChipOptions }, atecc608.ChipOptions{PowerOnSelfTest: true, KDFAESEnable: true, ECDHProtection: 2, KDFProtection: 1, IOProtectionKey: 9}},
+		{"X509format[3]", 95, []byte{0x3c}, func(c *atecc608.Config) any { return c.X509Format[3] }, atecc608.X509Format{PublicPosition: 0xc, TemplateLength: 3}},
+		{"KeyConfig[0]", 96, []byte{0x33, 0x00}, func(c *atecc608.Config) any { return c.Slots[0].KeyConfig }, atecc608.KeyConfig{Private: true, PubInfo: true, KeyType: 4, Lockable: true}},
+		{"KeyConfig[15]", 126, []byte{0x00, 0x5c}, func(c *atecc608.Config) any {
+			return [2]any{c.Slots[15].KeyConfig.AuthKey, c.Slots[15].KeyConfig.X509ID}
+		}, [2]any{0xc, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := make([]byte, atecc608.ConfigSize)
+			copy(buf[tt.off:], tt.val)
+
+			c, err := atecc608.DecodeConfig(buf)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := tt.got(c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := atecc608.DecodeConfig(make([]byte, atecc608.ConfigSize-1)); err == nil {
+		t.Error("expected invalid size error")
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	dev := setup(t)
+
+	buf, err := atecc608.ReadConfig()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := atecc608.DecodeConfig(buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(c.Serial, append(dev.Config[0:4:4], dev.Config[8:13]...)) {
+		t.Errorf("unexpected serial %s", c.Serial)
+	}
+
+	if c.DataLocked || c.ConfigLocked || c.I2CAddress != atecc608.I2CAddress {
+		t.Errorf("unexpected configuration %+v", c)
+	}
+}
@@ -0,0 +1,127 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package atecc608
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/usbarmory/armoryctl/internal"
+)
+
+// RandomSize is the size of the Random command output.
+const RandomSize = 32
+
+// Output returned by the Random command when the configuration zone is not
+// locked, in place of random data
+// (Random Command, ATECC608A Full Datasheet).
+var randomTestPattern = bytes.Repeat([]byte{0xff, 0xff, 0x00, 0x00}, RandomSize/4)
+
+// ErrRandomFailure is returned when the Random command output is not random,
+// as it matches the fixed pattern returned by devices with an unlocked
+// configuration zone or repeats the previous output.
+var ErrRandomFailure = errors.New("random number generator failure")
+
+// Random executes the Random command and returns 32 bytes from the device
+// random number generator.
+func (d *Device) Random() (res []byte, err error) {
+	return d.RandomContext(context.Background())
+}
+
+// RandomContext is like Random but the command is aborted once ctx is done.
+func (d *Device) RandomContext(ctx context.Context) (res []byte, err error) {
+	// param1 0x00: automatically update the seed, if needed
+	// param2 0x0000: must be zero
+	// data: 20 bytes, ignored
+	res, err = d.ExecuteCmdContext(ctx, Cmd["Random"], [1]byte{0x00}, [2]byte{0x00, 0x00}, make([]byte, 20), true)
+
+	if err != nil {
+		return
+	}
+
+	if len(res) != RandomSize {
+		return nil, armoryctl.Classify(errors.New("invalid random response size"), armoryctl.ErrChecksum)
+	}
+
+	if bytes.Equal(res, randomTestPattern) {
+		return nil, fmt.Errorf("%w, configuration zone unlocked", ErrRandomFailure)
+	}
+
+	return
+}
+
+// randomReader implements io.Reader over the Random command.
+type randomReader struct {
+	ctx  context.Context
+	d    *Device
+	buf  []byte
+	last []byte
+}
+
+// RandomReader returns an io.Reader of random data generated by the device,
+// Random is executed for each 32 bytes block.
+//
+// A block repeating the previous one results in ErrRandomFailure.
+func (d *Device) RandomReader() io.Reader {
+	return d.RandomReaderContext(context.Background())
+}
+
+// RandomReaderContext is like RandomReader but reads fail once ctx is done.
+func (d *Device) RandomReaderContext(ctx context.Context) io.Reader {
+	return &randomReader{ctx: ctx, d: d}
+}
+
+func (r *randomReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if len(r.buf) == 0 {
+			var block []byte
+
+			if block, err = r.d.RandomContext(r.ctx); err != nil {
+				return
+			}
+
+			if bytes.Equal(block, r.last) {
+				return n, fmt.Errorf("%w, repeated output", ErrRandomFailure)
+			}
+
+			r.last = block
+			r.buf = block
+		}
+
+		c := copy(p[n:], r.buf)
+		r.buf = r.buf[c:]
+		n += c
+	}
+
+	return
+}
+
+// ReadRandom fills buf with random data generated by the default instance
+// (see Device.RandomReader).
+func ReadRandom(buf []byte) (err error) {
+	return ReadRandomContext(context.Background(), buf)
+}
+
+// ReadRandomContext is like ReadRandom but the operation is aborted once ctx
+// is done.
+func ReadRandomContext(ctx context.Context, buf []byte) (err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	_, err = io.ReadFull(d.RandomReaderContext(ctx), buf)
+
+	return
+}
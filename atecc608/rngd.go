@@ -0,0 +1,132 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux
+
+package atecc608
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/usbarmory/armoryctl/internal"
+)
+
+// ErrHealthTest is returned when random data fails a continuous health test.
+var ErrHealthTest = armoryctl.ErrHealthTest
+
+// RNGDConfig represents the parameters for feeding the kernel entropy pool.
+type RNGDConfig struct {
+	// Size is the number of random bytes fed at each interval.
+	Size int
+	// Interval is the wait time between feeds, limiting the feed rate.
+	Interval time.Duration
+	// EntropyPerByte is the min-entropy estimate, in bits per byte, used
+	// to credit fed data and to set health test cutoffs.
+	EntropyPerByte float64
+	// Report, when not nil, is called with each feed error, including
+	// those after which feeding continues.
+	Report func(err error)
+}
+
+// RNGD holds the entropy feeding parameters, the default ones credit a
+// conservative 2 bits per byte while feeding at most 64 bytes per second.
+var RNGD = &RNGDConfig{
+	Size:           64,
+	Interval:       1 * time.Second,
+	EntropyPerByte: 2,
+}
+
+// FeedEntropy periodically reads random data from the default instance and
+// mixes it into the kernel entropy pool (see armoryctl.AddEntropy), until
+// ctx is done.
+//
+// Random data is subject to continuous health tests, a failure stops
+// feeding and returns ErrHealthTest or ErrRandomFailure. Failures to add
+// entropy to the kernel pool (e.g. missing privileges) also stop feeding,
+// while other errors are logged and feeding is attempted again at the next
+// interval. The I²C bus is only held while reading.
+func FeedEntropy(ctx context.Context) (err error) {
+	conf := *RNGD
+	buf := make([]byte, conf.Size)
+
+	health, err := armoryctl.NewHealthTest(conf.EntropyPerByte)
+
+	if err != nil {
+		return
+	}
+
+	bits := int(float64(conf.Size) * conf.EntropyPerByte)
+
+	for {
+		if err = feedEntropy(ctx, buf, health, bits); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			if conf.Report != nil {
+				conf.Report(err)
+			}
+
+			if errors.Is(err, ErrHealthTest) || errors.Is(err, ErrRandomFailure) || errors.As(err, new(*entropyError)) {
+				armoryctl.Log(slog.LevelError, "entropy feed stopped", armoryctl.LogDevice, "ATECC608", "error", err)
+				return
+			}
+
+			armoryctl.Log(slog.LevelWarn, "entropy feed failed", armoryctl.LogDevice, "ATECC608", "error", err)
+		}
+
+		if armoryctl.Sleep(ctx, conf.Interval) != nil {
+			return nil
+		}
+	}
+}
+
+// entropyError represents a failure to add entropy to the kernel pool, which
+// is not expected to be transient.
+type entropyError struct {
+	err error
+}
+
+func (e *entropyError) Error() string {
+	return "could not add entropy, " + e.err.Error()
+}
+
+func (e *entropyError) Unwrap() error {
+	return e.err
+}
+
+func feedEntropy(ctx context.Context, buf []byte, health *armoryctl.HealthTest, bits int) (err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+
+	_, err = io.ReadFull(d.RandomReaderContext(ctx), buf)
+	_ = bus.Close()
+
+	if err != nil {
+		return
+	}
+
+	if err = health.Test(buf); err != nil {
+		return
+	}
+
+	if err = armoryctl.AddEntropy(buf, bits); err != nil {
+		return &entropyError{err}
+	}
+
+	armoryctl.Log(slog.LevelDebug, "entropy fed", armoryctl.LogDevice, "ATECC608", "bytes", len(buf), "bits", bits)
+
+	return
+}
@@ -0,0 +1,205 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package atecc608
+
+import (
+	"context"
+)
+
+// KeyType values (KeyConfig, ATECC608A Full Datasheet).
+const (
+	KeyTypeP256 = 4
+	KeyTypeAES  = 6
+	KeyTypeSHA  = 7
+)
+
+// Slot access modes, as reported by SlotInfo.
+const (
+	AccessClear      = "clear"
+	AccessEncrypted  = "encrypted"
+	AccessPubInvalid = "pubinvalid"
+	AccessNever      = "never"
+)
+
+// SlotSize returns the data zone slot size in bytes.
+func SlotSize(slot int) int {
+	switch {
+	case slot < 8:
+		return 36
+	case slot == 8:
+		return 416
+	default:
+		return 72
+	}
+}
+
+// SlotInfo describes how a key slot can be used, as derived from the
+// configuration zone.
+type SlotInfo struct {
+	// Slot number
+	Index int `json:"index"`
+	// Key type description
+	KeyType string `json:"key_type"`
+	// Slot size in bytes
+	Size int `json:"size"`
+	// Locked is true when the slot is individually locked.
+	Locked bool `json:"locked"`
+	// Read is the Read command access mode.
+	Read string `json:"read"`
+	// Write is the Write (PrivWrite for private keys) command access
+	// mode.
+	Write string `json:"write"`
+	// EncryptionKey is the slot of the key used for encrypted reads or
+	// writes, -1 if not applicable.
+	EncryptionKey int `json:"encryption_key"`
+	// Sign is true when the key can be used by the Sign command.
+	Sign bool `json:"sign"`
+	// ECDH is true when the key can be used by the ECDH command.
+	ECDH bool `json:"ecdh"`
+	// GenKey is true when GenKey can generate a new key in the slot.
+	GenKey bool `json:"genkey"`
+	// LimitedUse is true when each key use decrements Counter[0].
+	LimitedUse bool `json:"limited_use"`
+	// CountMatch is true when the slot is the CountMatch key.
+	CountMatch bool `json:"count_match"`
+	// AuthKey is the slot of the key required for authorization, -1 if
+	// none.
+	AuthKey int `json:"auth_key"`
+}
+
+func keyType(kc KeyConfig) string {
+	switch kc.KeyType {
+	case KeyTypeP256:
+		if kc.Private {
+			return "P256 private"
+		}
+
+		return "P256 public"
+	case KeyTypeAES:
+		return "AES"
+	case KeyTypeSHA:
+		return "SHA/data"
+	default:
+		return "reserved"
+	}
+}
+
+// SlotInfo describes the argument slot configuration.
+func (c *Config) SlotInfo(slot int) (info *SlotInfo) {
+	s := c.Slots[slot]
+	sc := s.SlotConfig
+	kc := s.KeyConfig
+
+	private := kc.KeyType == KeyTypeP256 && kc.Private
+
+	info = &SlotInfo{
+		Index:         slot,
+		KeyType:       keyType(kc),
+		Size:          SlotSize(slot),
+		Locked:        s.Locked,
+		Read:          AccessNever,
+		Write:         AccessNever,
+		EncryptionKey: -1,
+		LimitedUse:    sc.LimitedUse,
+		CountMatch:    c.CountMatchEnable && c.CountMatchKey == slot,
+		AuthKey:       -1,
+	}
+
+	// The data zone can only be read once locked, private keys are
+	// never readable.
+	switch {
+	case !c.DataLocked || private:
+	case !sc.IsSecret:
+		info.Read = AccessClear
+	case sc.EncryptRead:
+		info.Read = AccessEncrypted
+		info.EncryptionKey = sc.ReadKey
+	}
+
+	// WriteConfig bits for the Write command: 0000 always, 0001
+	// PubInvalid, x1xx encrypted, all others never. WriteConfig bits for
+	// the PrivWrite command: 0xxx never, 1xxx encrypted.
+	switch {
+	case s.Locked:
+	case private && !c.DataLocked:
+		// PrivWrite accepts unencrypted input until the data zone is
+		// locked.
+		info.Write = AccessClear
+	case !c.DataLocked:
+		info.Write = AccessClear
+	case private:
+		if sc.WriteConfig&0x08 != 0 {
+			info.Write = AccessEncrypted
+			info.EncryptionKey = sc.WriteKey
+		}
+	case sc.WriteConfig&0x04 != 0:
+		info.Write = AccessEncrypted
+		info.EncryptionKey = sc.WriteKey
+	case sc.WriteConfig == 0x00:
+		info.Write = AccessClear
+	case sc.WriteConfig == 0x01:
+		info.Write = AccessPubInvalid
+	}
+
+	if private {
+		// ReadKey bits for private keys: external signatures (0),
+		// internal signatures (1), ECDH (2).
+		info.Sign = sc.ReadKey&0x03 != 0
+		info.ECDH = sc.ReadKey&0x04 != 0
+		// WriteConfig bit 1 enables GenKey random key generation.
+		info.GenKey = sc.WriteConfig&0x02 != 0 && !s.Locked
+	}
+
+	if kc.ReqAuth {
+		info.AuthKey = kc.AuthKey
+	}
+
+	return
+}
+
+// SlotsInfo reads the configuration zone and describes all key slots (see
+// Config.SlotInfo).
+func (d *Device) SlotsInfo() (slots []*SlotInfo, err error) {
+	return d.SlotsInfoContext(context.Background())
+}
+
+// SlotsInfoContext is like SlotsInfo but the command is aborted once ctx is
+// done.
+func (d *Device) SlotsInfoContext(ctx context.Context) (slots []*SlotInfo, err error) {
+	c, err := d.ConfigContext(ctx)
+
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < Slots; i++ {
+		slots = append(slots, c.SlotInfo(i))
+	}
+
+	return
+}
+
+// SlotsInfo describes all key slots of the default instance (see
+// Device.SlotsInfo).
+func SlotsInfo() (slots []*SlotInfo, err error) {
+	return SlotsInfoContext(context.Background())
+}
+
+// SlotsInfoContext is like SlotsInfo but the command is aborted once ctx is
+// done.
+func SlotsInfoContext(ctx context.Context) (slots []*SlotInfo, err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.SlotsInfoContext(ctx)
+}
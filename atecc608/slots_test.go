@@ -0,0 +1,108 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package atecc608_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/usbarmory/armoryctl/atecc608"
+)
+
+func testConfig(t *testing.T, slotConfig uint16, keyConfig uint16, dataLocked bool, slotLocked bool) *atecc608.Config {
+	buf := make([]byte, atecc608.ConfigSize)
+
+	binary.LittleEndian.PutUint16(buf[20:], slotConfig)
+	binary.LittleEndian.PutUint16(buf[96:], keyConfig)
+
+	buf[86] = 0x55
+	buf[88] = 0xff
+	buf[89] = 0xff
+
+	if dataLocked {
+		buf[86] = 0x00
+	}
+
+	if slotLocked {
+		buf[88] = 0xfe
+	}
+
+	c, err := atecc608.DecodeConfig(buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestSlotInfo(t *testing.T) {
+	const (
+		private = 0x0033 // P256 private key, PubInfo, Lockable
+		public  = 0x0030 // P256 public key, Lockable
+		data    = 0x003c // SHA/data, Lockable
+		auth    = 0x0380 | data
+	)
+
+	tests := []struct {
+		name       string
+		slotConfig uint16
+		keyConfig  uint16
+		dataLocked bool
+		slotLocked bool
+		want       atecc608.SlotInfo
+	}{
+		{"private encrypted", 0x8720, private, true, false, atecc608.SlotInfo{Read: "never", Write: "encrypted", EncryptionKey: 7, LimitedUse: true, AuthKey: -1}},
+		{"private unlocked", 0x8720, private, false, false, atecc608.SlotInfo{Read: "never", Write: "clear", EncryptionKey: -1, LimitedUse: true, AuthKey: -1}},
+		{"private genkey", 0x2087, private, true, false, atecc608.SlotInfo{Read: "never", Write: "never", EncryptionKey: -1, Sign: true, ECDH: true, GenKey: true, AuthKey: -1}},
+		{"private write never", 0x4087, private, true, false, atecc608.SlotInfo{Read: "never", Write: "never", EncryptionKey: -1, Sign: true, ECDH: true, AuthKey: -1}},
+		{"private locked", 0xa087, private, true, true, atecc608.SlotInfo{Locked: true, Read: "never", Write: "never", EncryptionKey: -1, Sign: true, ECDH: true, AuthKey: -1}},
+		{"public", 0x0000, public, true, false, atecc608.SlotInfo{Read: "clear", Write: "clear", EncryptionKey: -1, AuthKey: -1}},
+		{"public pubinvalid", 0x1000, public, true, false, atecc608.SlotInfo{Read: "clear", Write: "pubinvalid", EncryptionKey: -1, AuthKey: -1}},
+		{"data unlocked", 0x8000, data, false, false, atecc608.SlotInfo{Read: "never", Write: "clear", EncryptionKey: -1, AuthKey: -1}},
+		{"data secret", 0x8080, data, true, false, atecc608.SlotInfo{Read: "never", Write: "never", EncryptionKey: -1, AuthKey: -1}},
+		{"data encrypted", 0x45c3, data, true, false, atecc608.SlotInfo{Read: "encrypted", Write: "encrypted", EncryptionKey: 5, AuthKey: -1}},
+		{"data encrypted read", 0x80c3, data, true, false, atecc608.SlotInfo{Read: "encrypted", Write: "never", EncryptionKey: 3, AuthKey: -1}},
+		{"data locked", 0x0000, data, true, true, atecc608.SlotInfo{Locked: true, Read: "clear", Write: "never", EncryptionKey: -1, AuthKey: -1}},
+		{"auth", 0x0000, auth, true, false, atecc608.SlotInfo{Read: "clear", Write: "clear", EncryptionKey: -1, AuthKey: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testConfig(t, tt.slotConfig, tt.keyConfig, tt.dataLocked, tt.slotLocked)
+			info := c.SlotInfo(0)
+
+			want := tt.want
+			want.KeyType = info.KeyType
+			want.Size = 36
+
+			if *info != want {
+				t.Errorf("unexpected slot info\n%+v\nwant\n%+v", *info, want)
+			}
+		})
+	}
+}
+
+func TestSlotInfoCountMatch(t *testing.T) {
+	c := testConfig(t, 0x0000, 0x003c, true, false)
+	c.CountMatchEnable = true
+	c.CountMatchKey = 0
+
+	if !c.SlotInfo(0).CountMatch || c.SlotInfo(1).CountMatch {
+		t.Error("unexpected CountMatch slot")
+	}
+
+	for i, kt := range []string{"P256 private", "P256 public", "SHA/data"} {
+		c = testConfig(t, 0x0000, []uint16{0x0033, 0x0030, 0x003c}[i], true, false)
+
+		if info := c.SlotInfo(0); info.KeyType != kt {
+			t.Errorf("unexpected key type %s, want %s", info.KeyType, kt)
+		}
+	}
+}
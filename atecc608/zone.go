@@ -0,0 +1,225 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package atecc608
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Zone encoding
+// (Read Command, ATECC608A Full Datasheet).
+const (
+	ZoneConfig = 0x00
+	ZoneOTP    = 0x01
+	ZoneData   = 0x02
+)
+
+// Zone sizes in bytes.
+const (
+	BlockSize = 32
+	WordSize  = 4
+	OTPSize   = 64
+)
+
+// ErrZoneUnlocked is returned when reading the data or OTP zones before the
+// data zone is locked.
+var ErrZoneUnlocked = errors.New("data zone not locked")
+
+// zoneSize returns the size of the addressed zone, or data zone slot.
+func zoneSize(zone int, slot int) (size int, err error) {
+	switch zone {
+	case ZoneConfig:
+		size = ConfigSize
+	case ZoneOTP:
+		size = OTPSize
+	case ZoneData:
+		if slot < 0 || slot >= Slots {
+			return 0, fmt.Errorf("invalid slot %d", slot)
+		}
+
+		return SlotSize(slot), nil
+	default:
+		return 0, fmt.Errorf("invalid zone %d", zone)
+	}
+
+	if slot != 0 {
+		return 0, fmt.Errorf("invalid slot %d, only data zone has slots", slot)
+	}
+
+	return
+}
+
+func (d *Device) readZone(ctx context.Context, zone int, slot int, block int, offset int, length int) (res []byte, err error) {
+	var param1 byte
+	var addr uint16
+
+	switch length {
+	case WordSize:
+	case BlockSize:
+		if offset != 0 {
+			return nil, errors.New("32 bytes reads must be block aligned")
+		}
+
+		// read 32 bytes
+		param1 = 0x80
+	default:
+		return nil, fmt.Errorf("invalid read length %d, must be %d or %d", length, WordSize, BlockSize)
+	}
+
+	size, err := zoneSize(zone, slot)
+
+	if err != nil {
+		return
+	}
+
+	if block < 0 || offset < 0 || offset >= BlockSize/WordSize || block*BlockSize+offset*WordSize+length > size {
+		return nil, fmt.Errorf("read of %d bytes at block %d offset %d exceeds size (%d)", length, block, offset, size)
+	}
+
+	// Address encoding: data zone block <11:8>, slot <6:3>, offset <2:0>,
+	// configuration and OTP zones block <4:3>, offset <2:0>.
+	if zone == ZoneData {
+		addr = uint16(block)<<8 | uint16(slot)<<3 | uint16(offset)
+	} else {
+		addr = uint16(block)<<3 | uint16(offset)
+	}
+
+	res, err = d.ExecuteCmdContext(ctx, Cmd["Read"], [1]byte{param1 | byte(zone)}, [2]byte{byte(addr), byte(addr >> 8)}, nil, true)
+
+	if err != nil {
+		return
+	}
+
+	if len(res) != length {
+		return nil, fmt.Errorf("invalid read response size (%d)", len(res))
+	}
+
+	return
+}
+
+// checkZone verifies that the data zone is locked, when reading data or OTP
+// zones.
+func (d *Device) checkZone(ctx context.Context, zone int) (err error) {
+	if zone == ZoneConfig {
+		return
+	}
+
+	// configuration zone bytes 84-87: UserExtra, UserExtraAdd,
+	// LockValue, LockConfig
+	res, err := d.readZone(ctx, ZoneConfig, 0, 2, 5, WordSize)
+
+	if err != nil {
+		return
+	}
+
+	if res[2] == unlocked {
+		return ErrZoneUnlocked
+	}
+
+	return
+}
+
+// ReadZone reads 4 or 32 (length) bytes from a zone, at the argument block
+// and 4 bytes word offset. The slot is only used, and checked against its
+// size, for the data zone.
+//
+// Data and OTP zones can only be read once the data zone is locked,
+// ErrZoneUnlocked is returned otherwise.
+func (d *Device) ReadZone(zone int, slot int, block int, offset int, length int) (res []byte, err error) {
+	return d.ReadZoneContext(context.Background(), zone, slot, block, offset, length)
+}
+
+// ReadZoneContext is like ReadZone but the command is aborted once ctx is
+// done.
+func (d *Device) ReadZoneContext(ctx context.Context, zone int, slot int, block int, offset int, length int) (res []byte, err error) {
+	if err = d.checkZone(ctx, zone); err != nil {
+		return
+	}
+
+	return d.readZone(ctx, zone, slot, block, offset, length)
+}
+
+// ReadSlot reads the entire content of a data zone slot, or of the whole
+// configuration and OTP zones, using 32 bytes reads where possible.
+func (d *Device) ReadSlot(zone int, slot int) (res []byte, err error) {
+	return d.ReadSlotContext(context.Background(), zone, slot)
+}
+
+// ReadSlotContext is like ReadSlot but the command is aborted once ctx is
+// done.
+func (d *Device) ReadSlotContext(ctx context.Context, zone int, slot int) (res []byte, err error) {
+	size, err := zoneSize(zone, slot)
+
+	if err != nil {
+		return
+	}
+
+	if err = d.checkZone(ctx, zone); err != nil {
+		return
+	}
+
+	for len(res) < size {
+		var buf []byte
+
+		block := len(res) / BlockSize
+		offset := len(res) % BlockSize / WordSize
+		length := WordSize
+
+		if offset == 0 && len(res)+BlockSize <= size {
+			length = BlockSize
+		}
+
+		if buf, err = d.readZone(ctx, zone, slot, block, offset, length); err != nil {
+			return nil, err
+		}
+
+		res = append(res, buf...)
+	}
+
+	return
+}
+
+// ReadZone reads from a zone of the default instance (see Device.ReadZone).
+func ReadZone(zone int, slot int, block int, offset int, length int) (res []byte, err error) {
+	return ReadZoneContext(context.Background(), zone, slot, block, offset, length)
+}
+
+// ReadZoneContext is like ReadZone but the command is aborted once ctx is
+// done.
+func ReadZoneContext(ctx context.Context, zone int, slot int, block int, offset int, length int) (res []byte, err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.ReadZoneContext(ctx, zone, slot, block, offset, length)
+}
+
+// ReadSlot reads a data zone slot, or the whole configuration and OTP zones,
+// of the default instance (see Device.ReadSlot).
+func ReadSlot(zone int, slot int) (res []byte, err error) {
+	return ReadSlotContext(context.Background(), zone, slot)
+}
+
+// ReadSlotContext is like ReadSlot but the command is aborted once ctx is
+// done.
+func ReadSlotContext(ctx context.Context, zone int, slot int) (res []byte, err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.ReadSlotContext(ctx, zone, slot)
+}
@@ -19,11 +19,17 @@ import (
 	"github.com/usbarmory/armoryctl/internal"
 )
 
+// Default instance bus number and address, used by the package level
+// functions.
 var (
 	I2CBus     = 0
 	I2CAddress = 0x31
 )
 
+// Retry sets the I²C transfer retry policy, the global one is used when nil
+// (see armoryctl.Retry).
+var Retry *armoryctl.RetryPolicy
+
 // Current mode values and meaning
 // (FUSB303/D, Table 6).
 var CurrentMode = map[byte]string{
@@ -33,34 +39,317 @@ var CurrentMode = map[byte]string{
 	0x03: "3.0 A",
 }
 
+// Host current advertisement values and meaning
+// (CONTROL, FUSB303/D, Table 16).
+var HostCurrent = map[byte]string{
+	0x00: "reserved",
+	0x01: "default",
+	0x02: "1.5 A",
+	0x03: "3.0 A",
+}
+
+// Register map (FUSB303/D, Tables 12 to 26).
+var Registers = armoryctl.RegisterMap{
+	{
+		Name:    "DEVICE_ID",
+		Address: 0x01,
+		Access:  armoryctl.ReadOnly,
+		Fields: []*armoryctl.Field{
+			{Name: "VERSION_ID", Shift: 4, Width: 4},
+			{Name: "REVISION_ID", Shift: 0, Width: 4},
+		},
+	},
+	{
+		Name:    "DEVICE_TYPE",
+		Address: 0x02,
+		Access:  armoryctl.ReadOnly,
+	},
+	{
+		Name:    "PORTROLE",
+		Address: 0x03,
+		Fields: []*armoryctl.Field{
+			{Name: "ORIENTDEB", Shift: 6, Width: 1},
+			{
+				Name:  "TRY",
+				Shift: 4,
+				Width: 2,
+				Values: map[byte]string{
+					0x00: "normal",
+					0x01: "Try.SNK",
+					0x02: "Try.SRC",
+					0x03: "disabled",
+				},
+			},
+			{Name: "AUDIOACC", Shift: 3, Width: 1},
+			{Name: "DRP", Shift: 2, Width: 1},
+			{Name: "SNK", Shift: 1, Width: 1},
+			{Name: "SRC", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "CONTROL",
+		Address: 0x04,
+		Fields: []*armoryctl.Field{
+			{Name: "T_DRP", Shift: 6, Width: 2},
+			{Name: "DRPTOGGLE", Shift: 4, Width: 2},
+			{Name: "DCABLE_EN", Shift: 3, Width: 1},
+			{Name: "HOST_CUR", Shift: 1, Width: 2, Values: HostCurrent},
+			{Name: "INT_MASK", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "CONTROL1",
+		Address: 0x05,
+		Fields: []*armoryctl.Field{
+			{Name: "REMEDY_EN", Shift: 7, Width: 1},
+			{Name: "AUTO_SNK_TH", Shift: 5, Width: 2},
+			{Name: "AUTO_SNK_EN", Shift: 4, Width: 1},
+			{Name: "ENABLE", Shift: 3, Width: 1},
+			{Name: "TCCDEB", Shift: 0, Width: 3},
+		},
+	},
+	{
+		Name:    "MANUAL",
+		Address: 0x09,
+		Fields: []*armoryctl.Field{
+			{Name: "FORCE_SRC", Shift: 5, Width: 1},
+			{Name: "FORCE_SNK", Shift: 4, Width: 1},
+			{Name: "UNATT_SNK", Shift: 3, Width: 1},
+			{Name: "UNATT_SRC", Shift: 2, Width: 1},
+			{Name: "DISABLED", Shift: 1, Width: 1},
+			{Name: "ERROR_REC", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "RESET",
+		Address: 0x0a,
+		Fields: []*armoryctl.Field{
+			{Name: "SW_RES", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "MASK",
+		Address: 0x0e,
+		Fields: []*armoryctl.Field{
+			{Name: "M_ORIENT", Shift: 6, Width: 1},
+			{Name: "M_FAULT", Shift: 5, Width: 1},
+			{Name: "M_VBUS_CHG", Shift: 4, Width: 1},
+			{Name: "M_AUTOSNK", Shift: 3, Width: 1},
+			{Name: "M_BC_LVL", Shift: 2, Width: 1},
+			{Name: "M_DETACH", Shift: 1, Width: 1},
+			{Name: "M_ATTACH", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "MASK1",
+		Address: 0x0f,
+		Fields: []*armoryctl.Field{
+			{Name: "M_REM_VBOFF", Shift: 6, Width: 1},
+			{Name: "M_REM_VBON", Shift: 5, Width: 1},
+			{Name: "M_REM_FAIL", Shift: 3, Width: 1},
+			{Name: "M_FRC_FAIL", Shift: 2, Width: 1},
+			{Name: "M_FRC_SUCC", Shift: 1, Width: 1},
+			{Name: "M_REMEDY", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "STATUS",
+		Address: 0x11,
+		Access:  armoryctl.ReadOnly,
+		Fields: []*armoryctl.Field{
+			{Name: "AUTOSNK", Shift: 7, Width: 1},
+			{Name: "VSAFE0V", Shift: 6, Width: 1},
+			{
+				Name:  "ORIENT",
+				Shift: 4,
+				Width: 2,
+				Values: map[byte]string{
+					0x00: "no CC",
+					0x01: "CC1",
+					0x02: "CC2",
+					0x03: "fault",
+				},
+			},
+			{Name: "VBUSOK", Shift: 3, Width: 1},
+			{Name: "BC_LVL", Shift: 1, Width: 2, Values: CurrentMode},
+			{Name: "ATTACH", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "STATUS1",
+		Address: 0x12,
+		Access:  armoryctl.ReadOnly,
+		Fields: []*armoryctl.Field{
+			{Name: "FAULT", Shift: 1, Width: 1},
+			{Name: "REMEDY", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "TYPE",
+		Address: 0x13,
+		Access:  armoryctl.ReadOnly,
+		Fields: []*armoryctl.Field{
+			{Name: "DEBUGSRC", Shift: 6, Width: 1},
+			{Name: "DEBUGSNK", Shift: 5, Width: 1},
+			{Name: "SINK", Shift: 4, Width: 1},
+			{Name: "SOURCE", Shift: 3, Width: 1},
+			{Name: "ACTIVECABLE", Shift: 2, Width: 1},
+			{Name: "AUDIOVBUS", Shift: 1, Width: 1},
+			{Name: "AUDIO", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "INTERRUPT",
+		Address: 0x14,
+		Access:  armoryctl.WriteOneToClear,
+		Fields: []*armoryctl.Field{
+			{Name: "I_ORIENT", Shift: 6, Width: 1},
+			{Name: "I_FAULT", Shift: 5, Width: 1},
+			{Name: "I_VBUS_CHG", Shift: 4, Width: 1},
+			{Name: "I_AUTOSNK", Shift: 3, Width: 1},
+			{Name: "I_BC_LVL", Shift: 2, Width: 1},
+			{Name: "I_DETACH", Shift: 1, Width: 1},
+			{Name: "I_ATTACH", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "INTERRUPT1",
+		Address: 0x15,
+		Access:  armoryctl.WriteOneToClear,
+		Fields: []*armoryctl.Field{
+			{Name: "I_REM_VBOFF", Shift: 6, Width: 1},
+			{Name: "I_REM_VBON", Shift: 5, Width: 1},
+			{Name: "I_REM_FAIL", Shift: 3, Width: 1},
+			{Name: "I_FRC_FAIL", Shift: 2, Width: 1},
+			{Name: "I_FRC_SUCC", Shift: 1, Width: 1},
+			{Name: "I_REMEDY", Shift: 0, Width: 1},
+		},
+	},
+}
+
+// Device represents a FUSB303 controller instance.
+type Device struct {
+	// I²C bus transport
+	Bus armoryctl.I2C
+	// I²C slave address
+	Address int
+}
+
+// New returns a FUSB303 controller instance on the given bus and address.
+func New(bus armoryctl.I2C, addr int) *Device {
+	return &Device{
+		Bus:     bus,
+		Address: addr,
+	}
+}
+
+func openDefault() (d *Device, bus armoryctl.I2CHandle, err error) {
+	bus, err = armoryctl.OpenI2C(I2CBus)
+
+	if err != nil {
+		return
+	}
+
+	return New(bus, I2CAddress), bus, nil
+}
+
+// bus returns the device transport, retrying and logging transfers.
+func (d *Device) bus() armoryctl.I2C {
+	return armoryctl.WithLog(armoryctl.WithRetry(d.Bus, Retry), "FUSB303")
+}
+
 // Get device identifier, reading I2C data address 0x01
 // (DEVICE ID, (FUSB303/D, Table 13).
-func GetDeviceID() (id []byte, err error) {
-	return armoryctl.I2CRead(I2CBus, I2CAddress, 0x01, 1)
+func (d *Device) GetDeviceID() (id []byte, err error) {
+	r, err := Registers.Register("DEVICE_ID")
+
+	if err != nil {
+		return
+	}
+
+	return r.Read(d.bus(), d.Address)
 }
 
 // Get detected current advertisement, reading I2C data address 0x11 (STATUS,
 // (FUSB303/D, Table 22) and extracting value BC_LVL[1:0].
-func GetCurrentMode() (mode byte, err error) {
-	val, err := armoryctl.I2CRead(I2CBus, I2CAddress, 0x11, 1)
+func (d *Device) GetCurrentMode() (mode byte, err error) {
+	return Registers.ReadField(d.bus(), d.Address, "STATUS", "BC_LVL")
+}
+
+// Force enable, setting ENABLE in I2C data address 0x05
+// (CONTROL_1, FUSB303/D - Table 17).
+func (d *Device) Enable() (err error) {
+	return Registers.WriteField(d.bus(), d.Address, "CONTROL1", "ENABLE", 1, true)
+}
+
+// Force disable, clearing ENABLE in I2C data address 0x05
+// (CONTROL_1, FUSB303/D - Table 17).
+func (d *Device) Disable() (err error) {
+	return Registers.WriteField(d.bus(), d.Address, "CONTROL1", "ENABLE", 0, true)
+}
+
+// Dump all registers, decoding their fields.
+func (d *Device) Dump() (res string, err error) {
+	return Registers.Dump(d.bus(), d.Address)
+}
+
+// Get device identifier of the default instance (see Device.GetDeviceID).
+func GetDeviceID() (id []byte, err error) {
+	d, bus, err := openDefault()
 
 	if err != nil {
 		return
 	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.GetDeviceID()
+}
 
-	mode = (val[0] >> 1) & 3
+// Get detected current advertisement of the default instance (see
+// Device.GetCurrentMode).
+func GetCurrentMode() (mode byte, err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
 
-	return
+	return d.GetCurrentMode()
 }
 
-// Force enable, writing I2C data address 0x05
-// (CONTROL_1, FUSB303/D - Table 17).
+// Force enable the default instance (see Device.Enable).
 func Enable() (err error) {
-	return armoryctl.I2CWrite(I2CBus, I2CAddress, 0x05, []byte{0xbb})
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.Enable()
 }
 
-// Force disable, writing I2C data address 0x05
-// (CONTROL_1, FUSB303/D - Table 17).
+// Force disable the default instance (see Device.Disable).
 func Disable() (err error) {
-	return armoryctl.I2CWrite(I2CBus, I2CAddress, 0x05, []byte{0xb3})
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.Disable()
+}
+
+// Dump all registers of the default instance (see Device.Dump).
+func Dump() (res string, err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.Dump()
 }
@@ -0,0 +1,98 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package fusb303_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/usbarmory/armoryctl/fusb303"
+	"github.com/usbarmory/armoryctl/sim"
+)
+
+func TestGetDeviceID(t *testing.T) {
+	sim.Install(t, sim.NewBus())
+
+	id, err := fusb303.GetDeviceID()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// VERSION_ID (FUSB303/D, Table 13)
+	if len(id) != 1 || id[0]>>4 != 0x1 {
+		t.Errorf("unexpected device ID %#x", id)
+	}
+}
+
+func TestGetCurrentMode(t *testing.T) {
+	sim.Install(t, sim.NewBus())
+
+	mode, err := fusb303.GetCurrentMode()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fusb303.CurrentMode[mode] != "0.5 A" {
+		t.Errorf("unexpected current mode %#x", mode)
+	}
+}
+
+func TestEnable(t *testing.T) {
+	bus := sim.NewBus()
+	sim.Install(t, bus)
+
+	dev, _ := bus.Device(fusb303.I2CAddress)
+
+	for _, enable := range []bool{true, false} {
+		var err error
+
+		if enable {
+			err = fusb303.Enable()
+		} else {
+			err = fusb303.Disable()
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// CONTROL_1 ENABLE (FUSB303/D, Table 17)
+		val, err := dev.Read(0x05, 1)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if on := val[0]&0x08 != 0; on != enable {
+			t.Errorf("unexpected CONTROL_1 %#x after enable:%v", val[0], enable)
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	sim.Install(t, sim.NewBus())
+
+	res, err := fusb303.Dump()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"DEVICE_ID(0x01):0x10 VERSION_ID:0x1 REVISION_ID:0x0",
+		"ENABLE:0x0 ",
+		`BC_LVL:0x1("0.5 A")`,
+	} {
+		if !strings.Contains(res, want) {
+			t.Errorf("dump does not contain %s:\n%s", want, res)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package i2c provides access to the USB armory Mk II I²C buses, its
+// transports can be passed to the on-board chip drivers (e.g. fusb303.New).
+package i2c
+
+import (
+	"context"
+	"time"
+
+	"github.com/usbarmory/armoryctl/internal"
+)
+
+// Bus represents an I²C bus transport, any type implementing its methods can
+// be used in place of the system buses (e.g. to drive chips over a different
+// adapter or to inject fakes).
+type Bus = armoryctl.I2C
+
+// Handle represents an I²C bus transport which is held open until closed.
+type Handle = armoryctl.I2CHandle
+
+// New returns the transport for the numbered system I²C bus, the bus is
+// opened and closed on each transfer (see Open).
+func New(bus int) Bus {
+	return armoryctl.NewI2C(bus)
+}
+
+// Open opens the numbered system I²C bus, the returned handle keeps the bus
+// open across transfers until closed, it can be shared among multiple chip
+// driver instances.
+func Open(bus int) (Handle, error) {
+	return armoryctl.OpenI2C(bus)
+}
+
+// SetBackend replaces the transport of the numbered system I²C bus with the
+// argument one (e.g. a sim.Bus), affecting all package level chip driver
+// functions set to use that bus number. A nil backend restores the system
+// transport.
+func SetBackend(bus int, backend Bus) {
+	armoryctl.SetI2CBackend(bus, backend)
+}
+
+// Read reads size bytes from register reg of the slave at address addr on the
+// numbered system I²C bus.
+func Read(bus int, addr int, reg uint8, size uint) (val []byte, err error) {
+	return armoryctl.I2CRead(bus, addr, reg, size)
+}
+
+// Write writes val to register reg of the slave at address addr on the
+// numbered system I²C bus.
+func Write(bus int, addr int, reg uint8, val []byte) (err error) {
+	return armoryctl.I2CWrite(bus, addr, reg, val)
+}
+
+// Probe probes the slave at address addr with a single byte read transaction,
+// with no register address written, an error is returned when the slave does
+// not acknowledge it or the transport does not support probing.
+func Probe(bus Bus, addr int) (err error) {
+	return armoryctl.ProbeI2C(bus, addr)
+}
+
+// ReadContext is like Read but the transfer is not started once ctx is done.
+func ReadContext(ctx context.Context, bus int, addr int, reg uint8, size uint) (val []byte, err error) {
+	return armoryctl.I2CReadContext(ctx, bus, addr, reg, size)
+}
+
+// WriteContext is like Write but the transfer is not started once ctx is done.
+func WriteContext(ctx context.Context, bus int, addr int, reg uint8, val []byte) (err error) {
+	return armoryctl.I2CWriteContext(ctx, bus, addr, reg, val)
+}
+
+// ErrBusNotFound is returned when the numbered system I²C bus is not present.
+var ErrBusNotFound = armoryctl.ErrBusNotFound
+
+// ErrBusy is returned when a bus is held, by another process or another
+// handle within the process, for longer than the lock timeout (see
+// SetLockTimeout).
+var ErrBusy = armoryctl.ErrBusy
+
+// SetLockTimeout sets the maximum wait time for acquiring the exclusive lock
+// of a system I²C bus or serial device, which is held for the lifetime of
+// each open handle or port.
+func SetLockTimeout(timeout time.Duration) {
+	armoryctl.LockTimeout = timeout
+}
+
+// MismatchError is returned by Update when the register read back does not
+// hold the written value.
+type MismatchError = armoryctl.MismatchError
+
+// Update performs a read-modify-write of a single byte register, changing
+// only the bits set in mask, the register is optionally read back to verify
+// the write. The write-1-to-clear bits in w1c are written as 0 when not set
+// in mask, so that pending flags are preserved.
+func Update(bus Bus, addr int, reg uint8, mask byte, val byte, w1c byte, verify bool) (err error) {
+	return armoryctl.UpdateRegister(bus, addr, reg, mask, val, w1c, verify)
+}
@@ -8,6 +8,14 @@
 
 package armoryctl
 
+import (
+	"log/slog"
+)
+
+// Logger is the structured logger used by all transports and drivers,
+// logging is disabled when nil (see Log).
+var Logger *slog.Logger
+
 // build information, initialized at compile time (see Makefile)
 var Revision string
 var Build string
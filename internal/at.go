@@ -0,0 +1,237 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package armoryctl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ATTimeout is the default AT command response timeout.
+var ATTimeout = 5 * time.Second
+
+// ATPollInterval is the wait time between reads which returned no data.
+const ATPollInterval = 10 * time.Millisecond
+
+// AT final result codes
+const (
+	ATResultOK       = "OK"
+	ATResultError    = "ERROR"
+	ATResultCMEError = "+CME ERROR:"
+)
+
+// CommandError is returned when an AT command completes with an ERROR or
+// +CME ERROR final result code, it matches ErrDeviceStatus.
+type CommandError struct {
+	// Command is the AT command (e.g. AT+CGMI).
+	Command string
+	// Result is the final result code.
+	Result string
+	// Code is the +CME ERROR code, -1 when not available.
+	Code int
+}
+
+// NewCommandError returns the error for cmd completing with the argument
+// final result code.
+func NewCommandError(cmd string, result string) *CommandError {
+	e := &CommandError{
+		Command: cmd,
+		Result:  result,
+		Code:    -1,
+	}
+
+	if s, ok := strings.CutPrefix(result, ATResultCMEError); ok {
+		if code, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+			e.Code = code
+		}
+	}
+
+	return e
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%s response error (%s)", e.Command, e.Result)
+}
+
+func (e *CommandError) Unwrap() error {
+	return ErrDeviceStatus
+}
+
+// ATResponse represents the response to an AT command.
+type ATResponse struct {
+	// Lines holds the intermediate response lines, excluding any command
+	// echo and the final result code.
+	Lines []string `json:"lines,omitempty"`
+	// Result holds the final result code.
+	Result string `json:"result"`
+}
+
+// ATPort implements a line-oriented AT command transport over a serial port.
+//
+// The port Read() is expected to return, with no error, when no data is
+// available within a short time (e.g. a serial read timeout), so that
+// deadlines can be enforced.
+type ATPort struct {
+	rw  io.ReadWriter
+	buf []byte
+}
+
+// NewATPort returns an AT command transport over the argument serial port.
+func NewATPort(rw io.ReadWriter) *ATPort {
+	return &ATPort{rw: rw}
+}
+
+// ReadLine returns the next non-empty line received, lines can be terminated
+// by either CR or LF.
+func (p *ATPort) ReadLine(ctx context.Context, deadline time.Time) (line string, err error) {
+	var n int
+	r := make([]byte, 256)
+
+	for {
+		for {
+			i := bytes.IndexAny(p.buf, "\r\n")
+
+			if i < 0 {
+				break
+			}
+
+			line = strings.TrimSpace(string(p.buf[:i]))
+			p.buf = p.buf[i+1:]
+
+			if len(line) > 0 {
+				return
+			}
+		}
+
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return "", Classify(errors.New("response timeout"), ErrTimeout)
+		}
+
+		n, err = p.rw.Read(r)
+
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		if n == 0 {
+			if err = Sleep(ctx, ATPollInterval); err != nil {
+				return "", err
+			}
+
+			continue
+		}
+
+		p.buf = append(p.buf, r[:n]...)
+	}
+}
+
+// Drain discards any pending input, such as unsolicited result codes or
+// leftovers of previous commands, until no data is received.
+func (p *ATPort) Drain(ctx context.Context, deadline time.Time) (err error) {
+	var n int
+	r := make([]byte, 256)
+
+	p.buf = nil
+
+	for {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return Classify(errors.New("input drain timeout"), ErrTimeout)
+		}
+
+		if n, err = p.rw.Read(r); err != nil || n == 0 {
+			return
+		}
+
+		Log(LevelTrace, "AT discard", Payload(r[:n]))
+	}
+}
+
+// IsATResult returns whether the argument line is an AT final result code.
+func IsATResult(line string) bool {
+	return line == ATResultOK || line == ATResultError || strings.HasPrefix(line, ATResultCMEError)
+}
+
+// ReadATResponse collects the response to cmd from the lines returned by
+// next, until the final result code.
+//
+// The command echo, when enabled, is detected and discarded. An ERROR or
+// +CME ERROR final result code is returned as error along with the response.
+func ReadATResponse(cmd string, next func() (string, error)) (res *ATResponse, err error) {
+	var line string
+
+	res = &ATResponse{}
+	echo := true
+
+	for {
+		if line, err = next(); err != nil {
+			return nil, fmt.Errorf("%s %w", cmd, err)
+		}
+
+		if echo && line == cmd {
+			echo = false
+			continue
+		}
+
+		echo = false
+
+		if IsATResult(line) {
+			res.Result = line
+			break
+		}
+
+		res.Lines = append(res.Lines, line)
+	}
+
+	if res.Result != ATResultOK {
+		err = NewCommandError(cmd, res.Result)
+	}
+
+	return
+}
+
+// Command discards any pending input, sends an AT command, terminated with
+// CR, and reads its response (see ReadATResponse) until the final result
+// code or the timeout expiration (ATTimeout when zero).
+func (p *ATPort) Command(ctx context.Context, cmd string, timeout time.Duration) (res *ATResponse, err error) {
+	if timeout == 0 {
+		timeout = ATTimeout
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	if err = p.Drain(ctx, deadline); err != nil {
+		return
+	}
+
+	if _, err = p.rw.Write([]byte(cmd + "\r")); err != nil {
+		return
+	}
+
+	return ReadATResponse(cmd, func() (string, error) {
+		return p.ReadLine(ctx, deadline)
+	})
+}
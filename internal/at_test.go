@@ -0,0 +1,72 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux tamago
+
+package armoryctl
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCommandError(t *testing.T) {
+	tests := []struct {
+		result string
+		code   int
+		msg    string
+	}{
+		{"ERROR", -1, "AT+UBTLN=1 response error (ERROR)"},
+		{"+CME ERROR: 4", 4, "AT+UBTLN=1 response error (+CME ERROR: 4)"},
+		{"+CME ERROR:12", 12, "AT+UBTLN=1 response error (+CME ERROR:12)"},
+		{"+CME ERROR: unknown", -1, "AT+UBTLN=1 response error (+CME ERROR: unknown)"},
+	}
+
+	for _, tt := range tests {
+		var err error = NewCommandError("AT+UBTLN=1", tt.result)
+		err = fmt.Errorf("wrapped, %w", err)
+
+		var e *CommandError
+
+		if !errors.As(err, &e) {
+			t.Fatalf("%s: not a command error", tt.result)
+		}
+
+		if e.Code != tt.code || e.Result != tt.result || e.Command != "AT+UBTLN=1" {
+			t.Errorf("%s: unexpected error %+v", tt.result, e)
+		}
+
+		if e.Error() != tt.msg {
+			t.Errorf("%s: unexpected message %q", tt.result, e.Error())
+		}
+
+		if !errors.Is(err, ErrDeviceStatus) || IsTransient(err) {
+			t.Errorf("%s: unexpected error class", tt.result)
+		}
+	}
+}
+
+func TestReadATResponseError(t *testing.T) {
+	lines := []string{"AT+CGMM", "+CME ERROR: 3"}
+
+	res, err := ReadATResponse("AT+CGMM", func() (line string, err error) {
+		line, lines = lines[0], lines[1:]
+		return
+	})
+
+	var e *CommandError
+
+	if !errors.As(err, &e) || e.Code != 3 {
+		t.Fatalf("expected command error, got %v", err)
+	}
+
+	if res == nil || res.Result != "+CME ERROR: 3" || len(res.Lines) != 0 {
+		t.Errorf("unexpected response %+v", res)
+	}
+}
@@ -0,0 +1,17 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux
+
+package armoryctl
+
+// InitBLE has no effect as the BLE module UART and control pads are
+// configured by the kernel (device tree).
+func InitBLE() error {
+	return nil
+}
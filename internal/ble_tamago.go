@@ -0,0 +1,43 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build tamago,arm
+
+package armoryctl
+
+import (
+	"sync"
+
+	usbarmory "github.com/usbarmory/tamago/board/usbarmory/mk2"
+)
+
+var (
+	bleOnce sync.Once
+	bleErr  error
+)
+
+// InitBLE configures, once, the BLE module UART and control pads, the module
+// is reset in normal mode.
+func InitBLE() error {
+	bleOnce.Do(func() {
+		bleErr = usbarmory.BLE.Init()
+
+		if bleErr != nil {
+			return
+		}
+
+		// control pins are configured as outputs by the board package
+		for _, num := range []int{usbarmory.BT_RESET, usbarmory.BT_SWITCH_1, usbarmory.BT_SWITCH_2} {
+			if _, bleErr = gpioPin(num, true); bleErr != nil {
+				return
+			}
+		}
+	})
+
+	return bleErr
+}
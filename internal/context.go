@@ -0,0 +1,28 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package armoryctl
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep pauses for duration d, or until ctx is done in which case the context
+// error is returned.
+func Sleep(ctx context.Context, d time.Duration) (err error) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return
+	}
+}
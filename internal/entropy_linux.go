@@ -0,0 +1,46 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux
+
+package armoryctl
+
+import (
+	"encoding/binary"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// RNDADDENTROPY ioctl, _IOW('R', 0x03, int[2])
+const rndAddEntropy = 0x40085203
+
+// AddEntropy mixes data into the kernel entropy pool, crediting the argument
+// number of bits, root privileges are required.
+func AddEntropy(data []byte, bits int) (err error) {
+	f, err := os.OpenFile("/dev/random", os.O_WRONLY, 0)
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }() // make errcheck happy
+
+	// struct rand_pool_info:
+	//   entropy_count [4] | buf_size [4] | buf [buf_size]
+	info := make([]byte, 8+len(data))
+
+	binary.NativeEndian.PutUint32(info[0:], uint32(bits))
+	binary.NativeEndian.PutUint32(info[4:], uint32(len(data)))
+	copy(info[8:], data)
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), rndAddEntropy, uintptr(unsafe.Pointer(&info[0]))); errno != 0 {
+		return os.NewSyscallError("RNDADDENTROPY", errno)
+	}
+
+	return
+}
@@ -0,0 +1,62 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package armoryctl
+
+import (
+	"errors"
+)
+
+// Error classes, errors returned by transports and drivers match one of them
+// with errors.Is when their cause is known.
+var (
+	// the bus, or device node, is not present
+	ErrDeviceMissing = errors.New("device missing")
+	// the slave did not acknowledge the transfer
+	ErrNACK = errors.New("NACK")
+	// the response failed integrity checks
+	ErrChecksum = errors.New("checksum verification failure")
+	// the device reported an error status
+	ErrDeviceStatus = errors.New("device status error")
+	// the device did not respond in time
+	ErrTimeout = errors.New("timeout")
+)
+
+// ErrBusNotFound is returned when an I²C bus, or serial device, is not
+// present, it matches ErrDeviceMissing.
+var ErrBusNotFound = Classify(errors.New("bus not found"), ErrDeviceMissing)
+
+// classError attaches an error class to an error, preserving its message.
+type classError struct {
+	err   error
+	class error
+}
+
+func (e *classError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classError) Unwrap() []error {
+	return []error{e.err, e.class}
+}
+
+// Classify returns err marked as belonging to class (e.g. ErrNACK), so that
+// errors.Is(err, class) is true while the error message is unchanged.
+func Classify(err error, class error) error {
+	if err == nil || errors.Is(err, class) {
+		return err
+	}
+
+	return &classError{err: err, class: class}
+}
+
+// IsTransient returns whether an error is of a transient class (NACK,
+// checksum or timeout), which is worth retrying.
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrNACK) || errors.Is(err, ErrChecksum) || errors.Is(err, ErrTimeout)
+}
@@ -6,51 +6,83 @@
 // Use of this source code is governed by the license
 // that can be found in the LICENSE file.
 
-// +build linux
+// +build linux tamago
 
 package armoryctl
 
 import (
-	"fmt"
-	"log"
-
-	"periph.io/x/conn/v3/gpio"
-	"periph.io/x/conn/v3/gpio/gpioreg"
-	"periph.io/x/host/v3"
+	"time"
 )
 
-func findGPIO(name string) (pin gpio.PinIO, err error) {
-	_, err = host.Init()
+// GPIONoTimeout, as GPIOWaitForEdge timeout, waits for an edge with no time
+// limit, any negative timeout has the same meaning on all backends.
+const GPIONoTimeout time.Duration = -1
 
-	if err != nil {
-		return
-	}
+// GPIOEdge represents a GPIO input edge detection mode.
+type GPIOEdge int
 
-	pin = gpioreg.ByName(name)
-	if pin == nil {
-		err = fmt.Errorf("Failed to find gpio %s" + name)
-	}
+// GPIO edge detection modes.
+const (
+	GPIOBothEdges GPIOEdge = iota
+	GPIORisingEdge
+	GPIOFallingEdge
+)
 
-	return pin, err
+func (e GPIOEdge) String() string {
+	switch e {
+	case GPIOBothEdges:
+		return "both"
+	case GPIORisingEdge:
+		return "rising"
+	case GPIOFallingEdge:
+		return "falling"
+	default:
+		return "invalid"
+	}
 }
 
-// Configure a GPIO pin as output high or low.
-func GPIOSetOutput(name string, high bool) (err error) {
-	p, err := findGPIO(name)
+// GPIOBias represents a GPIO input bias setting.
+type GPIOBias int
 
-	if err != nil {
-		return
-	}
-
-	if Logger != nil {
-		log.Printf("GPIO %s high:%v\n", name, high)
-	}
+// GPIO bias settings.
+const (
+	GPIOBiasAsIs GPIOBias = iota
+	GPIOPullUp
+	GPIOPullDown
+	GPIOBiasDisabled
+)
 
-	if high {
-		err = p.Out(gpio.High)
-	} else {
-		err = p.Out(gpio.Low)
+func (b GPIOBias) String() string {
+	switch b {
+	case GPIOBiasAsIs:
+		return "as-is"
+	case GPIOPullUp:
+		return "pull-up"
+	case GPIOPullDown:
+		return "pull-down"
+	case GPIOBiasDisabled:
+		return "disabled"
+	default:
+		return "invalid"
 	}
+}
 
-	return
+// GPIOConfig represents a GPIO pin configuration.
+type GPIOConfig struct {
+	// Name is the pin name (e.g. GPIO9).
+	Name string `json:"name"`
+	// Number is the pin number.
+	Number int `json:"number"`
+	// Function is the pin function (e.g. Out/High).
+	Function string `json:"function"`
+	// Output is true when the pin is configured as output.
+	Output bool `json:"output"`
+	// High is true when the pin level is high.
+	High bool `json:"high"`
+	// Chip is the GPIO controller, when known (e.g. gpiochip0).
+	Chip string `json:"chip,omitempty"`
+	// Consumer is the pin consumer label, when in use.
+	Consumer string `json:"consumer,omitempty"`
+	// Bias is the pin bias setting, when known.
+	Bias string `json:"bias,omitempty"`
 }
@@ -0,0 +1,535 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+//
+// Links:
+//   https://docs.kernel.org/userspace-api/gpio/chardev.html
+//   https://github.com/torvalds/linux/blob/master/include/uapi/linux/gpio.h
+
+// +build linux
+
+package armoryctl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// GPIOConsumer is the consumer label of GPIO lines requested through the
+// character device interface.
+var GPIOConsumer = "armoryctl"
+
+// number of lines of each i.MX6 GPIO controller, used to map GPIO<n> names
+// to controller lines
+const gpioBankSize = 32
+
+// GPIO v2 uAPI definitions
+const (
+	gpioMaxNameSize    = 32
+	gpioV2LinesMax     = 64
+	gpioV2NumAttrsMax  = 10
+	gpioV2AttrFlags    = 1
+	gpioV2AttrOutput   = 2
+	gpioV2EventRising  = 1
+	gpioV2EventFalling = 2
+)
+
+// GPIO v2 line flags
+const (
+	gpioV2FlagUsed         = 1 << 0
+	gpioV2FlagActiveLow    = 1 << 1
+	gpioV2FlagInput        = 1 << 2
+	gpioV2FlagOutput       = 1 << 3
+	gpioV2FlagEdgeRising   = 1 << 4
+	gpioV2FlagEdgeFalling  = 1 << 5
+	gpioV2FlagBiasPullUp   = 1 << 8
+	gpioV2FlagBiasPullDown = 1 << 9
+	gpioV2FlagBiasDisabled = 1 << 10
+
+	gpioV2FlagBias = gpioV2FlagBiasPullUp | gpioV2FlagBiasPullDown | gpioV2FlagBiasDisabled
+)
+
+type gpioChipInfo struct {
+	name  [gpioMaxNameSize]byte
+	label [gpioMaxNameSize]byte
+	lines uint32
+}
+
+type gpioV2LineValues struct {
+	bits uint64
+	mask uint64
+}
+
+type gpioV2LineAttribute struct {
+	id      uint32
+	padding uint32
+	// union of flags, values and debounce_period_us
+	value uint64
+}
+
+type gpioV2LineConfigAttribute struct {
+	attr gpioV2LineAttribute
+	mask uint64
+}
+
+type gpioV2LineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [gpioV2NumAttrsMax]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	offsets         [gpioV2LinesMax]uint32
+	consumer        [gpioMaxNameSize]byte
+	config          gpioV2LineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+type gpioV2LineInfo struct {
+	name     [gpioMaxNameSize]byte
+	consumer [gpioMaxNameSize]byte
+	offset   uint32
+	numAttrs uint32
+	flags    uint64
+	attrs    [gpioV2NumAttrsMax]gpioV2LineAttribute
+	padding  [4]uint32
+}
+
+type gpioV2LineEvent struct {
+	timestamp uint64
+	id        uint32
+	offset    uint32
+	seqno     uint32
+	lineSeqno uint32
+	padding   [6]uint32
+}
+
+func gpioIOC(dir uintptr, nr uintptr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | 0xb4<<8 | nr
+}
+
+var (
+	gpioGetChipInfoIoctl     = gpioIOC(2, 0x01, unsafe.Sizeof(gpioChipInfo{}))
+	gpioV2GetLineInfoIoctl   = gpioIOC(3, 0x05, unsafe.Sizeof(gpioV2LineInfo{}))
+	gpioV2GetLineIoctl       = gpioIOC(3, 0x07, unsafe.Sizeof(gpioV2LineRequest{}))
+	gpioV2LineSetConfigIoctl = gpioIOC(3, 0x0d, unsafe.Sizeof(gpioV2LineConfig{}))
+	gpioV2LineGetValuesIoctl = gpioIOC(3, 0x0e, unsafe.Sizeof(gpioV2LineValues{}))
+)
+
+func gpioIoctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return string(b)
+}
+
+// gpioLine represents a GPIO line requested through the character device
+// interface.
+//
+// Requested lines are held until process exit, as the kernel does not
+// guarantee the line state once released.
+type gpioLine struct {
+	sync.Mutex
+
+	chip   string
+	offset uint32
+	fd     int
+	flags  uint64
+}
+
+var (
+	gpioLinesMutex sync.Mutex
+	gpioLines      = make(map[string]*gpioLine)
+)
+
+// chardevGPIO implements GPIO access through the character device uAPI v2.
+//
+// Pins can be named as <chip>:<offset> (e.g. gpiochip0:9), by line name or,
+// as with sysfs, as GPIO<n> which maps to line n%32 of gpiochip<n/32>.
+type chardevGPIO struct{}
+
+func openChip(chip string) (*os.File, error) {
+	return os.OpenFile(filepath.Join("/dev", chip), os.O_RDWR, 0)
+}
+
+func lineInfo(f *os.File, offset uint32) (info *gpioV2LineInfo, err error) {
+	info = &gpioV2LineInfo{offset: offset}
+	err = gpioIoctl(f.Fd(), gpioV2GetLineInfoIoctl, unsafe.Pointer(info))
+	return
+}
+
+// findLineByName searches all controllers for a line with the argument name.
+func findLineByName(name string) (chip string, offset uint32, err error) {
+	chips, err := filepath.Glob("/dev/gpiochip*")
+
+	if err != nil {
+		return
+	}
+
+	for _, path := range chips {
+		f, err := os.Open(path)
+
+		if err != nil {
+			continue
+		}
+
+		found, off := func() (bool, uint32) {
+			defer func() { _ = f.Close() }() // make errcheck happy
+
+			var ci gpioChipInfo
+
+			if gpioIoctl(f.Fd(), gpioGetChipInfoIoctl, unsafe.Pointer(&ci)) != nil {
+				return false, 0
+			}
+
+			for off := uint32(0); off < ci.lines; off++ {
+				if info, err := lineInfo(f, off); err == nil && cString(info.name[:]) == name {
+					return true, off
+				}
+			}
+
+			return false, 0
+		}()
+
+		if found {
+			return filepath.Base(path), off, nil
+		}
+	}
+
+	return "", 0, fmt.Errorf("failed to find gpio %s", name)
+}
+
+func findLine(name string) (chip string, offset uint32, err error) {
+	if c, o, ok := strings.Cut(name, ":"); ok {
+		off, err := strconv.ParseUint(o, 10, 32)
+
+		if err != nil || !strings.HasPrefix(filepath.Base(c), "gpiochip") {
+			return "", 0, fmt.Errorf("failed to find gpio %s", name)
+		}
+
+		return filepath.Base(c), uint32(off), nil
+	}
+
+	if chip, offset, err = findLineByName(name); err == nil {
+		return
+	}
+
+	if n, err := strconv.ParseUint(strings.TrimPrefix(name, "GPIO"), 10, 32); err == nil && strings.HasPrefix(name, "GPIO") {
+		return fmt.Sprintf("gpiochip%d", n/gpioBankSize), uint32(n % gpioBankSize), nil
+	}
+
+	return
+}
+
+// requestLine returns the line matching name, configured with the argument
+// flags and output level, the line is requested on first use.
+func requestLine(name string, flags uint64, high bool) (l *gpioLine, err error) {
+	chip, offset, err := findLine(name)
+
+	if err != nil {
+		return
+	}
+
+	gpioLinesMutex.Lock()
+	defer gpioLinesMutex.Unlock()
+
+	var conf gpioV2LineConfig
+
+	conf.flags = flags
+
+	if flags&gpioV2FlagOutput != 0 {
+		conf.numAttrs = 1
+		conf.attrs[0].attr.id = gpioV2AttrOutput
+		conf.attrs[0].mask = 1
+
+		if high {
+			conf.attrs[0].attr.value = 1
+		}
+	}
+
+	key := fmt.Sprintf("%s:%d", chip, offset)
+
+	if l = gpioLines[key]; l != nil {
+		l.Lock()
+		defer l.Unlock()
+
+		if err = gpioIoctl(uintptr(l.fd), gpioV2LineSetConfigIoctl, unsafe.Pointer(&conf)); err != nil {
+			return nil, fmt.Errorf("failed to configure gpio %s, %v", name, err)
+		}
+
+		l.flags = flags
+
+		return
+	}
+
+	f, err := openChip(chip)
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }() // make errcheck happy
+
+	req := &gpioV2LineRequest{
+		config:   conf,
+		numLines: 1,
+	}
+
+	req.offsets[0] = offset
+	copy(req.consumer[:gpioMaxNameSize-1], GPIOConsumer)
+
+	if err = gpioIoctl(f.Fd(), gpioV2GetLineIoctl, unsafe.Pointer(req)); err != nil {
+		return nil, fmt.Errorf("failed to request gpio %s, %v", name, err)
+	}
+
+	syscall.CloseOnExec(int(req.fd))
+
+	l = &gpioLine{
+		chip:   chip,
+		offset: offset,
+		fd:     int(req.fd),
+		flags:  flags,
+	}
+
+	gpioLines[key] = l
+
+	return
+}
+
+// heldLine returns the line matching name if already requested.
+func heldLine(name string) *gpioLine {
+	chip, offset, err := findLine(name)
+
+	if err != nil {
+		return nil
+	}
+
+	gpioLinesMutex.Lock()
+	defer gpioLinesMutex.Unlock()
+
+	return gpioLines[fmt.Sprintf("%s:%d", chip, offset)]
+}
+
+func (l *gpioLine) value() (high bool, err error) {
+	l.Lock()
+	defer l.Unlock()
+
+	vals := gpioV2LineValues{mask: 1}
+
+	if err = gpioIoctl(uintptr(l.fd), gpioV2LineGetValuesIoctl, unsafe.Pointer(&vals)); err != nil {
+		return
+	}
+
+	return vals.bits&1 == 1, nil
+}
+
+// wait waits for a line event, reporting whether one occurred before the
+// timeout (negative for no timeout).
+func (l *gpioLine) wait(timeout time.Duration) (detected bool, err error) {
+	var ev gpioV2LineEvent
+
+	pfd := struct {
+		fd      int32
+		events  int16
+		revents int16
+	}{
+		fd:     int32(l.fd),
+		events: 0x1, // POLLIN
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		var ts *syscall.Timespec
+
+		if timeout >= 0 {
+			t := syscall.NsecToTimespec(max(time.Until(deadline), 0).Nanoseconds())
+			ts = &t
+		}
+
+		n, _, errno := syscall.Syscall6(syscall.SYS_PPOLL, uintptr(unsafe.Pointer(&pfd)), 1, uintptr(unsafe.Pointer(ts)), 0, 0, 0)
+
+		if errno == syscall.EINTR {
+			continue
+		}
+
+		if errno != 0 {
+			return false, errno
+		}
+
+		if n == 0 {
+			return false, nil
+		}
+
+		break
+	}
+
+	buf := (*[unsafe.Sizeof(ev)]byte)(unsafe.Pointer(&ev))
+
+	if _, err = syscall.Read(l.fd, buf[:]); err != nil {
+		return
+	}
+
+	return ev.id == gpioV2EventRising || ev.id == gpioV2EventFalling, nil
+}
+
+func (chardevGPIO) setOutput(name string, high bool) (err error) {
+	_, err = requestLine(name, gpioV2FlagOutput, high)
+	return
+}
+
+func (chardevGPIO) setInput(name string, bias GPIOBias) (err error) {
+	flags := uint64(gpioV2FlagInput)
+
+	switch bias {
+	case GPIOBiasAsIs:
+		// keep the bias of already requested lines
+		if l := heldLine(name); l != nil {
+			flags |= l.flags & gpioV2FlagBias
+		}
+	case GPIOPullUp:
+		flags |= gpioV2FlagBiasPullUp
+	case GPIOPullDown:
+		flags |= gpioV2FlagBiasPullDown
+	case GPIOBiasDisabled:
+		flags |= gpioV2FlagBiasDisabled
+	default:
+		return fmt.Errorf("invalid gpio bias %d", bias)
+	}
+
+	_, err = requestLine(name, flags, false)
+
+	return
+}
+
+func (chardevGPIO) read(name string) (high bool, err error) {
+	l := heldLine(name)
+
+	if l == nil {
+		// request the line as-is, leaving its direction unchanged
+		if l, err = requestLine(name, 0, false); err != nil {
+			return
+		}
+	}
+
+	return l.value()
+}
+
+func (d chardevGPIO) config(name string) (conf *GPIOConfig, err error) {
+	chip, offset, err := findLine(name)
+
+	if err != nil {
+		return
+	}
+
+	f, err := openChip(chip)
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }() // make errcheck happy
+
+	info, err := lineInfo(f, offset)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gpio %s configuration, %v", name, err)
+	}
+
+	conf = &GPIOConfig{
+		Name:     cString(info.name[:]),
+		Number:   int(offset),
+		Function: "In",
+		Output:   info.flags&gpioV2FlagOutput != 0,
+		Chip:     chip,
+		Consumer: cString(info.consumer[:]),
+	}
+
+	if conf.Name == "" {
+		conf.Name = name
+	}
+
+	if conf.Output {
+		conf.Function = "Out"
+	}
+
+	switch {
+	case info.flags&gpioV2FlagBiasPullUp != 0:
+		conf.Bias = GPIOPullUp.String()
+	case info.flags&gpioV2FlagBiasPullDown != 0:
+		conf.Bias = GPIOPullDown.String()
+	case info.flags&gpioV2FlagBiasDisabled != 0:
+		conf.Bias = GPIOBiasDisabled.String()
+	}
+
+	// the level is not available for lines used by other consumers
+	if info.flags&gpioV2FlagUsed != 0 && heldLine(name) == nil {
+		return
+	}
+
+	if conf.High, err = d.read(name); err != nil {
+		return nil, err
+	}
+
+	if conf.High {
+		conf.Function += "/High"
+	} else {
+		conf.Function += "/Low"
+	}
+
+	return
+}
+
+func (chardevGPIO) waitForEdge(name string, edge GPIOEdge, timeout time.Duration) (detected bool, err error) {
+	flags := uint64(gpioV2FlagInput)
+
+	switch edge {
+	case GPIOBothEdges:
+		flags |= gpioV2FlagEdgeRising | gpioV2FlagEdgeFalling
+	case GPIORisingEdge:
+		flags |= gpioV2FlagEdgeRising
+	case GPIOFallingEdge:
+		flags |= gpioV2FlagEdgeFalling
+	}
+
+	if l := heldLine(name); l != nil {
+		flags |= l.flags & gpioV2FlagBias
+	}
+
+	l, err := requestLine(name, flags, false)
+
+	if err != nil {
+		return
+	}
+	defer func() { _, _ = requestLine(name, flags&^(gpioV2FlagEdgeRising|gpioV2FlagEdgeFalling), false) }() // make errcheck happy
+
+	if detected, err = l.wait(timeout); errors.Is(err, syscall.EAGAIN) {
+		err = nil
+	}
+
+	return
+}
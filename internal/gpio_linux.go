@@ -0,0 +1,287 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux
+
+package armoryctl
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpioreg"
+	"periph.io/x/host/v3"
+)
+
+// GPIO access methods.
+const (
+	// GPIOAuto selects sysfs when available, the character device
+	// otherwise.
+	GPIOAuto = "auto"
+	// GPIOSysfs selects the deprecated /sys/class/gpio interface.
+	GPIOSysfs = "sysfs"
+	// GPIOChardev selects the /dev/gpiochipN character device interface.
+	GPIOChardev = "chardev"
+)
+
+// GPIOBackend is the GPIO access method.
+var GPIOBackend = GPIOAuto
+
+// sysfs GPIO export file, its presence denotes sysfs GPIO support
+const gpioSysfsExport = "/sys/class/gpio/export"
+
+// gpioDriver represents a GPIO access method.
+type gpioDriver interface {
+	setOutput(name string, high bool) error
+	setInput(name string, bias GPIOBias) error
+	read(name string) (bool, error)
+	config(name string) (*GPIOConfig, error)
+	// waitForEdge waits with no time limit when timeout is negative
+	// (see GPIONoTimeout).
+	waitForEdge(name string, edge GPIOEdge, timeout time.Duration) (bool, error)
+}
+
+var (
+	gpioAutoOnce   sync.Once
+	gpioAutoDriver gpioDriver
+)
+
+func getGPIODriver() (d gpioDriver, err error) {
+	switch GPIOBackend {
+	case GPIOSysfs:
+		return sysfsGPIO{}, nil
+	case GPIOChardev:
+		return chardevGPIO{}, nil
+	case GPIOAuto, "":
+		gpioAutoOnce.Do(func() {
+			if _, err := os.Stat(gpioSysfsExport); err == nil {
+				gpioAutoDriver = sysfsGPIO{}
+			} else {
+				gpioAutoDriver = chardevGPIO{}
+			}
+		})
+
+		return gpioAutoDriver, nil
+	default:
+		return nil, fmt.Errorf("invalid gpio backend %s", GPIOBackend)
+	}
+}
+
+// Configure a GPIO pin as output high or low.
+func GPIOSetOutput(name string, high bool) (err error) {
+	d, err := getGPIODriver()
+
+	if err != nil {
+		return
+	}
+
+	Log(slog.LevelDebug, "GPIO set", "pin", name, "high", high)
+
+	return d.setOutput(name, high)
+}
+
+// Configure a GPIO pin as input with the argument bias setting.
+func GPIOSetInput(name string, bias GPIOBias) (err error) {
+	d, err := getGPIODriver()
+
+	if err != nil {
+		return
+	}
+
+	Log(slog.LevelDebug, "GPIO input", "pin", name, "bias", bias)
+
+	return d.setInput(name, bias)
+}
+
+// Read the level of a GPIO pin, its configuration is left unchanged.
+func GPIORead(name string) (high bool, err error) {
+	d, err := getGPIODriver()
+
+	if err != nil {
+		return
+	}
+
+	if high, err = d.read(name); err != nil {
+		return
+	}
+
+	Log(slog.LevelDebug, "GPIO read", "pin", name, "high", high)
+
+	return
+}
+
+// Get the configuration of a GPIO pin.
+func GPIOGetConfig(name string) (conf *GPIOConfig, err error) {
+	d, err := getGPIODriver()
+
+	if err != nil {
+		return
+	}
+
+	return d.config(name)
+}
+
+// Configure a GPIO pin as input and wait for the argument edge, detected is
+// false when no edge occurs within timeout. A negative timeout waits with no
+// time limit (see GPIONoTimeout).
+func GPIOWaitForEdge(name string, edge GPIOEdge, timeout time.Duration) (detected bool, err error) {
+	d, err := getGPIODriver()
+
+	if err != nil {
+		return
+	}
+
+	if edge < GPIOBothEdges || edge > GPIOFallingEdge {
+		return false, fmt.Errorf("invalid gpio edge %d", edge)
+	}
+
+	Log(slog.LevelDebug, "GPIO wait", "pin", name, "edge", edge, "timeout", timeout)
+
+	if detected, err = d.waitForEdge(name, edge, timeout); err != nil {
+		return
+	}
+
+	Log(slog.LevelDebug, "GPIO edge", "pin", name, "detected", detected)
+
+	return
+}
+
+// sysfsGPIO implements GPIO access through the periph sysfs driver.
+type sysfsGPIO struct{}
+
+func findGPIO(name string) (pin gpio.PinIO, err error) {
+	_, err = host.Init()
+
+	if err != nil {
+		return
+	}
+
+	pin = gpioreg.ByName(name)
+	if pin == nil {
+		err = fmt.Errorf("failed to find gpio %s", name)
+	}
+
+	return pin, err
+}
+
+func (sysfsGPIO) setOutput(name string, high bool) (err error) {
+	p, err := findGPIO(name)
+
+	if err != nil {
+		return
+	}
+
+	if high {
+		err = p.Out(gpio.High)
+	} else {
+		err = p.Out(gpio.Low)
+	}
+
+	return
+}
+
+func (sysfsGPIO) setInput(name string, bias GPIOBias) (err error) {
+	var pull gpio.Pull
+
+	p, err := findGPIO(name)
+
+	if err != nil {
+		return
+	}
+
+	switch bias {
+	case GPIOBiasAsIs:
+		pull = gpio.PullNoChange
+	case GPIOPullUp:
+		pull = gpio.PullUp
+	case GPIOPullDown:
+		pull = gpio.PullDown
+	case GPIOBiasDisabled:
+		pull = gpio.Float
+	default:
+		return fmt.Errorf("invalid gpio bias %d", bias)
+	}
+
+	return p.In(pull, gpio.NoEdge)
+}
+
+func (sysfsGPIO) read(name string) (high bool, err error) {
+	p, err := findGPIO(name)
+
+	if err != nil {
+		return
+	}
+
+	// the pin function must be queried first, as it initializes the
+	// pin value access
+	if p.Function() == "" {
+		return false, fmt.Errorf("failed to read gpio %s", name)
+	}
+
+	return bool(p.Read()), nil
+}
+
+func (sysfsGPIO) config(name string) (conf *GPIOConfig, err error) {
+	p, err := findGPIO(name)
+
+	if err != nil {
+		return
+	}
+
+	f := p.Function()
+
+	if f == "" {
+		return nil, fmt.Errorf("failed to read gpio %s configuration", name)
+	}
+
+	conf = &GPIOConfig{
+		Name:     p.Name(),
+		Number:   p.Number(),
+		Function: f,
+		Output:   strings.HasPrefix(f, "Out"),
+		High:     strings.HasSuffix(f, "High"),
+	}
+
+	return
+}
+
+func (sysfsGPIO) waitForEdge(name string, edge GPIOEdge, timeout time.Duration) (detected bool, err error) {
+	var e gpio.Edge
+
+	p, err := findGPIO(name)
+
+	if err != nil {
+		return
+	}
+
+	switch edge {
+	case GPIOBothEdges:
+		e = gpio.BothEdges
+	case GPIORisingEdge:
+		e = gpio.RisingEdge
+	case GPIOFallingEdge:
+		e = gpio.FallingEdge
+	}
+
+	if err = p.In(gpio.PullNoChange, e); err != nil {
+		return
+	}
+	defer func() { _ = p.In(gpio.PullNoChange, gpio.NoEdge) }() // make errcheck happy
+
+	// periph only waits with no time limit on -1
+	if timeout < 0 {
+		timeout = -1
+	}
+
+	return p.WaitForEdge(timeout), nil
+}
@@ -0,0 +1,231 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build tamago,arm
+
+package armoryctl
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/usbarmory/tamago/soc/nxp/gpio"
+	"github.com/usbarmory/tamago/soc/nxp/imx6ul"
+)
+
+// edge detection polling interval
+const gpioPollInterval = 1 * time.Millisecond
+
+// tamagoPin represents a GPIO1 pin, its direction is tracked as the driver
+// does not expose it.
+type tamagoPin struct {
+	*gpio.Pin
+
+	num    int
+	output bool
+}
+
+var (
+	gpioMutex sync.Mutex
+	gpioPins  = make(map[int]*tamagoPin)
+)
+
+// gpioPin returns the GPIO1 pin instance, pins not yet configured by this
+// package are assumed to be inputs (the controller reset default).
+func gpioPin(num int, output bool) (p *tamagoPin, err error) {
+	gpioMutex.Lock()
+	defer gpioMutex.Unlock()
+
+	if p, ok := gpioPins[num]; ok {
+		return p, nil
+	}
+
+	pin, err := imx6ul.GPIO1.Init(num)
+
+	if err != nil {
+		return
+	}
+
+	p = &tamagoPin{
+		Pin:    pin,
+		num:    num,
+		output: output,
+	}
+
+	gpioPins[num] = p
+
+	return
+}
+
+// findGPIO returns the GPIO1 pin matching name (e.g. GPIO9), its pad must be
+// configured as GPIO by the caller (e.g. InitBLE for the BLE module control
+// pins).
+func findGPIO(name string) (p *tamagoPin, err error) {
+	num, err := strconv.Atoi(strings.TrimPrefix(name, "GPIO"))
+
+	if err != nil || !strings.HasPrefix(name, "GPIO") {
+		return nil, fmt.Errorf("failed to find gpio %s", name)
+	}
+
+	return gpioPin(num, false)
+}
+
+func (p *tamagoPin) setOutput(output bool) {
+	gpioMutex.Lock()
+	defer gpioMutex.Unlock()
+
+	if output {
+		p.Out()
+	} else {
+		p.In()
+	}
+
+	p.output = output
+}
+
+func (p *tamagoPin) function() string {
+	gpioMutex.Lock()
+	defer gpioMutex.Unlock()
+
+	dir := "In"
+	level := "Low"
+
+	if p.output {
+		dir = "Out"
+	}
+
+	if p.Value() {
+		level = "High"
+	}
+
+	return dir + "/" + level
+}
+
+// Configure a GPIO pin as output high or low.
+func GPIOSetOutput(name string, high bool) (err error) {
+	p, err := findGPIO(name)
+
+	if err != nil {
+		return
+	}
+
+	Log(slog.LevelDebug, "GPIO set", "pin", name, "high", high)
+
+	if high {
+		p.High()
+	} else {
+		p.Low()
+	}
+
+	p.setOutput(true)
+
+	return
+}
+
+// Configure a GPIO pin as input, bias settings other than GPIOBiasAsIs are
+// not supported as they belong to the pad configuration.
+func GPIOSetInput(name string, bias GPIOBias) (err error) {
+	p, err := findGPIO(name)
+
+	if err != nil {
+		return
+	}
+
+	if bias != GPIOBiasAsIs {
+		return fmt.Errorf("gpio bias %s not supported", bias)
+	}
+
+	Log(slog.LevelDebug, "GPIO input", "pin", name, "bias", bias)
+
+	p.setOutput(false)
+
+	return
+}
+
+// Read the level of a GPIO pin, its configuration is left unchanged.
+func GPIORead(name string) (high bool, err error) {
+	p, err := findGPIO(name)
+
+	if err != nil {
+		return
+	}
+
+	high = p.Value()
+
+	Log(slog.LevelDebug, "GPIO read", "pin", name, "high", high)
+
+	return
+}
+
+// Get the configuration of a GPIO pin.
+func GPIOGetConfig(name string) (conf *GPIOConfig, err error) {
+	p, err := findGPIO(name)
+
+	if err != nil {
+		return
+	}
+
+	f := p.function()
+
+	conf = &GPIOConfig{
+		Name:     name,
+		Number:   p.num,
+		Function: f,
+		Output:   strings.HasPrefix(f, "Out"),
+		High:     strings.HasSuffix(f, "High"),
+	}
+
+	return
+}
+
+// Configure a GPIO pin as input and wait for the argument edge, detected is
+// false when no edge occurs within timeout. A negative timeout waits with no
+// time limit (see GPIONoTimeout). Edges are detected by polling the pin level.
+func GPIOWaitForEdge(name string, edge GPIOEdge, timeout time.Duration) (detected bool, err error) {
+	p, err := findGPIO(name)
+
+	if err != nil {
+		return
+	}
+
+	if edge < GPIOBothEdges || edge > GPIOFallingEdge {
+		return false, fmt.Errorf("invalid gpio edge %d", edge)
+	}
+
+	p.setOutput(false)
+
+	Log(slog.LevelDebug, "GPIO wait", "pin", name, "edge", edge, "timeout", timeout)
+
+	deadline := time.Now().Add(timeout)
+	last := p.Value()
+
+	for timeout < 0 || time.Now().Before(deadline) {
+		time.Sleep(gpioPollInterval)
+
+		high := p.Value()
+
+		if high == last {
+			continue
+		}
+
+		last = high
+
+		if edge == GPIOBothEdges || (edge == GPIORisingEdge) == high {
+			detected = true
+			break
+		}
+	}
+
+	Log(slog.LevelDebug, "GPIO edge", "pin", name, "detected", detected)
+
+	return
+}
@@ -0,0 +1,117 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+//
+// Links:
+//   https://nvlpubs.nist.gov/nistpubs/SpecialPublications/NIST.SP.800-90B.pdf
+
+package armoryctl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrHealthTest is returned when random data fails a continuous health test.
+var ErrHealthTest = errors.New("health test failure")
+
+// Continuous health test parameters
+// (4.4 Approved Continuous Health Tests, NIST SP 800-90B).
+const (
+	// false positive probability exponent (α = 2^-20)
+	healthAlpha = 20
+	// Adaptive Proportion Test window size for non-binary samples
+	healthWindow = 512
+)
+
+// HealthTest implements the Repetition Count and Adaptive Proportion
+// continuous health tests on byte samples, its state is preserved across
+// calls to Test.
+type HealthTest struct {
+	// test cutoff values
+	rctCutoff int
+	aptCutoff int
+
+	// Repetition Count Test state
+	last  byte
+	count int
+
+	// Adaptive Proportion Test state
+	first   byte
+	matches int
+	samples int
+}
+
+// NewHealthTest returns the continuous health tests for a source with the
+// argument min-entropy, in bits per byte.
+func NewHealthTest(h float64) (t *HealthTest, err error) {
+	if h <= 0 || h > 8 {
+		return nil, fmt.Errorf("invalid min-entropy %v", h)
+	}
+
+	t = &HealthTest{
+		rctCutoff: 1 + int(math.Ceil(healthAlpha/h)),
+		aptCutoff: 1 + critBinom(healthWindow, math.Pow(2, -h), 1-math.Pow(2, -healthAlpha)),
+	}
+
+	return
+}
+
+// critBinom returns the smallest k for which the binomial cumulative
+// distribution of n trials, with success probability p, is at least q.
+func critBinom(n int, p float64, q float64) int {
+	var cdf float64
+
+	lgn, _ := math.Lgamma(float64(n + 1))
+
+	for k := 0; k < n; k++ {
+		lgk, _ := math.Lgamma(float64(k + 1))
+		lgnk, _ := math.Lgamma(float64(n - k + 1))
+
+		cdf += math.Exp(lgn - lgk - lgnk + float64(k)*math.Log(p) + float64(n-k)*math.Log1p(-p))
+
+		if cdf >= q {
+			return k
+		}
+	}
+
+	return n
+}
+
+// Test runs the health tests on the argument samples.
+func (t *HealthTest) Test(data []byte) (err error) {
+	for _, b := range data {
+		if t.count > 0 && b == t.last {
+			t.count++
+		} else {
+			t.last = b
+			t.count = 1
+		}
+
+		if t.count >= t.rctCutoff {
+			return fmt.Errorf("%w, repetition count (%d repetitions of %#.2x)", ErrHealthTest, t.count, b)
+		}
+
+		if t.samples == 0 {
+			t.first = b
+			t.matches = 1
+		} else if b == t.first {
+			t.matches++
+		}
+
+		if t.matches >= t.aptCutoff {
+			return fmt.Errorf("%w, adaptive proportion (%d occurrences of %#.2x)", ErrHealthTest, t.matches, t.first)
+		}
+
+		if t.samples++; t.samples == healthWindow {
+			t.samples = 0
+		}
+	}
+
+	return
+}
@@ -0,0 +1,104 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package armoryctl
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestHealthTestCutoffs(t *testing.T) {
+	// Adaptive Proportion Test cutoffs from Table 2 (4.4.2, NIST SP 800-90B)
+	tests := []struct {
+		h   float64
+		rct int
+		apt int
+	}{
+		{0.5, 41, 410},
+		{1, 21, 311},
+		{2, 11, 177},
+		{4, 6, 62},
+		{8, 4, 13},
+	}
+
+	for _, tt := range tests {
+		ht, err := NewHealthTest(tt.h)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ht.rctCutoff != tt.rct || ht.aptCutoff != tt.apt {
+			t.Errorf("H=%v: unexpected cutoffs RCT:%d APT:%d, want RCT:%d APT:%d", tt.h, ht.rctCutoff, ht.aptCutoff, tt.rct, tt.apt)
+		}
+	}
+
+	for _, h := range []float64{0, -1, 8.1} {
+		if _, err := NewHealthTest(h); err == nil {
+			t.Errorf("H=%v: expected invalid min-entropy error", h)
+		}
+	}
+}
+
+// distinct returns n samples which differ from each other and from b.
+func distinct(b byte, n int) (data []byte) {
+	for v := b + 1; len(data) < n; v++ {
+		if v != b {
+			data = append(data, v)
+		}
+	}
+
+	return
+}
+
+func TestHealthTest(t *testing.T) {
+	tests := []struct {
+		name string
+		data [][]byte
+		fail bool
+	}{
+		{"repetition below cutoff", [][]byte{{0xaa, 0xaa, 0xaa}}, false},
+		{"repetition", [][]byte{{0xaa, 0xaa, 0xaa, 0xaa}}, true},
+		// state is preserved across calls
+		{"repetition across calls", [][]byte{{0x01, 0xaa, 0xaa}, {0xaa, 0xaa}}, true},
+		{"proportion below cutoff", [][]byte{bytes.Repeat([]byte{0xaa, 0x55}, 12)}, false},
+		{"proportion", [][]byte{bytes.Repeat([]byte{0xaa, 0x55}, 13)}, true},
+		// the proportion is counted within each window
+		{"proportion window", [][]byte{
+			bytes.Repeat([]byte{0xaa, 0x55}, 12),
+			distinct(0xaa, 512-24),
+			bytes.Repeat([]byte{0xaa, 0x55}, 12),
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ht, err := NewHealthTest(8)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, data := range tt.data {
+				if err = ht.Test(data); err != nil {
+					break
+				}
+			}
+
+			if tt.fail && !errors.Is(err, ErrHealthTest) {
+				t.Errorf("expected health test failure, got %v", err)
+			}
+
+			if !tt.fail && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
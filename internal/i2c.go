@@ -0,0 +1,225 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux tamago
+
+package armoryctl
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// I2C represents an I²C bus transport, all transfers address a register of
+// the target slave device.
+type I2C interface {
+	// Read reads size bytes from register reg of the slave at address
+	// addr.
+	Read(addr int, reg uint8, size uint) (val []byte, err error)
+	// Write writes val to register reg of the slave at address addr.
+	Write(addr int, reg uint8, val []byte) (err error)
+}
+
+// I2CHandle represents an I²C bus transport which is held open, across any
+// number of transfers, until closed.
+type I2CHandle interface {
+	I2C
+	// Close releases the bus.
+	Close() (err error)
+}
+
+// I2CProber represents an I²C transport able to probe a slave address with a
+// single byte read transaction, with no register address written (as
+// i2cdetect -r).
+type I2CProber interface {
+	// Probe returns an error when the slave at address addr does not
+	// acknowledge a read transaction.
+	Probe(addr int) (err error)
+}
+
+// ProbeI2C probes the slave at address addr with a read-only transaction,
+// an error is returned when the transport does not support probing (see
+// I2CProber).
+func ProbeI2C(bus I2C, addr int) (err error) {
+	p, ok := bus.(I2CProber)
+
+	if !ok {
+		return errors.New("I2C transport does not support probing")
+	}
+
+	return p.Probe(addr)
+}
+
+// nopHandle represents a transport which does not require to be released.
+type nopHandle struct {
+	I2C
+}
+
+func (h nopHandle) Probe(addr int) (err error) {
+	return ProbeI2C(h.I2C, addr)
+}
+
+func (h nopHandle) Close() (err error) {
+	return
+}
+
+// logI2C represents a device transport emitting a debug event for each
+// transfer.
+type logI2C struct {
+	bus    I2C
+	device string
+}
+
+// WithLog returns a transport logging transfers on bus as events of the named
+// device (e.g. TUSB320).
+func WithLog(bus I2C, device string) I2C {
+	return &logI2C{bus: bus, device: device}
+}
+
+func (l *logI2C) Read(addr int, reg uint8, size uint) (val []byte, err error) {
+	start := time.Now()
+	val, err = l.bus.Read(addr, reg, size)
+
+	l.log("register read", addr, reg, start, val, err)
+
+	return
+}
+
+func (l *logI2C) Write(addr int, reg uint8, val []byte) (err error) {
+	start := time.Now()
+	err = l.bus.Write(addr, reg, val)
+
+	l.log("register write", addr, reg, start, val, err)
+
+	return
+}
+
+func (l *logI2C) log(msg string, addr int, reg uint8, start time.Time, val []byte, err error) {
+	if !LogEnabled(slog.LevelDebug) {
+		return
+	}
+
+	attrs := []any{LogDevice, l.device, LogAddress, addr, LogRegister, reg, LogDuration, time.Since(start), Payload(val)}
+
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+
+	Log(slog.LevelDebug, msg, attrs...)
+}
+
+var (
+	i2cMutex    sync.Mutex
+	i2cBackends = make(map[int]I2C)
+)
+
+// SetI2CBackend replaces the transport of the numbered system I²C bus with
+// the argument one (e.g. a simulated bus), a nil backend restores the system
+// transport.
+func SetI2CBackend(bus int, backend I2C) {
+	i2cMutex.Lock()
+	defer i2cMutex.Unlock()
+
+	if backend == nil {
+		delete(i2cBackends, bus)
+	} else {
+		i2cBackends[bus] = backend
+	}
+}
+
+func i2cBackend(bus int) (backend I2C, ok bool) {
+	i2cMutex.Lock()
+	defer i2cMutex.Unlock()
+
+	backend, ok = i2cBackends[bus]
+
+	return
+}
+
+// OpenI2C opens the numbered I²C bus, the returned handle reuses the same bus
+// access for all transfers, until closed, allowing multi-step transactions to
+// be performed without re-opening the bus on each transfer.
+func OpenI2C(bus int) (handle I2CHandle, err error) {
+	if backend, ok := i2cBackend(bus); ok {
+		handle = nopHandle{backend}
+	} else if handle, err = openI2C(bus); err != nil {
+		return
+	}
+
+	if rec := Recording; rec != nil {
+		handle = rec.i2cHandle(bus, handle)
+	}
+
+	return
+}
+
+// I2CRead reads size bytes from register reg of the slave at address addr on
+// the numbered I²C bus, the bus is opened and closed for the transfer.
+func I2CRead(bus int, addr int, reg uint8, size uint) (val []byte, err error) {
+	h, err := OpenI2C(bus)
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = h.Close() }() // make errcheck happy
+
+	return h.Read(addr, reg, size)
+}
+
+// I2CWrite writes val to register reg of the slave at address addr on the
+// numbered I²C bus, the bus is opened and closed for the transfer.
+func I2CWrite(bus int, addr int, reg uint8, val []byte) (err error) {
+	h, err := OpenI2C(bus)
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = h.Close() }() // make errcheck happy
+
+	return h.Write(addr, reg, val)
+}
+
+// I2CReadContext is like I2CRead but the transfer is not started once ctx is
+// done.
+func I2CReadContext(ctx context.Context, bus int, addr int, reg uint8, size uint) (val []byte, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	return I2CRead(bus, addr, reg, size)
+}
+
+// I2CWriteContext is like I2CWrite but the transfer is not started once ctx
+// is done.
+func I2CWriteContext(ctx context.Context, bus int, addr int, reg uint8, val []byte) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	return I2CWrite(bus, addr, reg, val)
+}
+
+// i2cBus represents a system I²C bus, each transfer is performed with
+// I2CRead() and I2CWrite().
+type i2cBus int
+
+func (bus i2cBus) Read(addr int, reg uint8, size uint) (val []byte, err error) {
+	return I2CRead(int(bus), addr, reg, size)
+}
+
+func (bus i2cBus) Write(addr int, reg uint8, val []byte) (err error) {
+	return I2CWrite(int(bus), addr, reg, val)
+}
+
+// NewI2C returns the transport for the numbered system I²C bus.
+func NewI2C(bus int) I2C {
+	return i2cBus(bus)
+}
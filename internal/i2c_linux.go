@@ -11,31 +11,81 @@
 package armoryctl
 
 import (
+	"errors"
 	"fmt"
-	"log"
 	"os"
+	"strings"
+	"sync"
+	"syscall"
 
+	"periph.io/x/conn/v3/i2c"
 	"periph.io/x/conn/v3/i2c/i2creg"
 	"periph.io/x/host/v3"
 )
 
+// i2cHandle represents an open system I²C bus.
+type i2cHandle struct {
+	sync.Mutex
+
+	bus  int
+	b    i2c.BusCloser
+	lock *Lock
+}
+
+func i2cDevice(bus int) string {
+	return fmt.Sprintf("/dev/i2c-%d", bus)
+}
+
 func checkI2C(bus int) (err error) {
-	dev := fmt.Sprintf("/dev/i2c-%d", bus)
+	dev := i2cDevice(bus)
 
 	if _, err = os.Stat(dev); os.IsNotExist(err) {
-		err = fmt.Errorf("%s missing, ensure that i2c-dev kernel module is loaded", dev)
+		err = Classify(fmt.Errorf("%s missing, ensure that i2c-dev kernel module is loaded", dev), ErrBusNotFound)
 	}
 
 	return
 }
 
-func I2CRead(bus int, addr int, reg uint8, size uint) (val []byte, err error) {
+// i2cError classifies transfer errors, the errno is only available as part of
+// the error message.
+func i2cError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	msg := err.Error()
+
+	switch {
+	case strings.Contains(msg, syscall.EREMOTEIO.Error()), strings.Contains(msg, syscall.ENXIO.Error()):
+		return Classify(err, ErrNACK)
+	case strings.Contains(msg, syscall.ETIMEDOUT.Error()):
+		return Classify(err, ErrTimeout)
+	}
+
+	return err
+}
+
+func openI2C(bus int) (handle I2CHandle, err error) {
 	err = checkI2C(bus)
 
 	if err != nil {
 		return
 	}
 
+	// the bus is locked for the handle lifetime, so that multi-step
+	// transactions are not interleaved with other processes
+	lock, err := LockPath(i2cDevice(bus), LockTimeout)
+
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			_ = lock.Unlock()
+		}
+	}()
+
 	_, err = host.Init()
 
 	if err != nil {
@@ -47,58 +97,79 @@ func I2CRead(bus int, addr int, reg uint8, size uint) (val []byte, err error) {
 	if err != nil {
 		return
 	}
-	defer func() { _ = b.Close() }() // make errcheck happy
 
-	w := []byte{byte(reg)}
-	r := make([]byte, size)
+	return &i2cHandle{bus: bus, b: b, lock: lock}, nil
+}
+
+func (h *i2cHandle) Read(addr int, reg uint8, size uint) (val []byte, err error) {
+	h.Lock()
+	defer h.Unlock()
 
-	if Logger != nil {
-		log.Printf("I2C read addr:%#x reg:%#x\n", addr, reg)
+	if h.b == nil {
+		return nil, errors.New("I2C bus is closed")
 	}
 
-	err = b.Tx(uint16(addr), w, r)
+	w := []byte{byte(reg)}
+	r := make([]byte, size)
+
+	err = i2cError(h.b.Tx(uint16(addr), w, r))
 
 	if err != nil {
 		return
 	}
 
-	if Logger != nil {
-		log.Printf("I2C read: %#x\n", r)
-	}
+	Log(LevelTrace, "I2C read", LogBus, h.bus, LogAddress, addr, LogRegister, reg, Payload(r))
 
 	return r, nil
 }
 
-func I2CWrite(bus int, addr int, reg uint8, val []byte) (err error) {
-	err = checkI2C(bus)
+func (h *i2cHandle) Write(addr int, reg uint8, val []byte) (err error) {
+	h.Lock()
+	defer h.Unlock()
 
-	if err != nil {
-		return
+	if h.b == nil {
+		return errors.New("I2C bus is closed")
 	}
 
-	_, err = host.Init()
+	var w []byte
 
-	if err != nil {
-		return
-	}
+	w = append(w, byte(reg))
+	w = append(w, val...)
 
-	b, err := i2creg.Open(fmt.Sprintf("%d", bus))
+	Log(LevelTrace, "I2C write", LogBus, h.bus, LogAddress, addr, LogRegister, reg, Payload(val))
 
-	if err != nil {
-		return
+	return i2cError(h.b.Tx(uint16(addr), w, nil))
+}
+
+func (h *i2cHandle) Probe(addr int) (err error) {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.b == nil {
+		return errors.New("I2C bus is closed")
 	}
-	defer func() { _ = b.Close() }() // make errcheck happy
 
-	var w []byte
+	r := make([]byte, 1)
 
-	w = append(w, byte(reg))
-	w = append(w, val...)
+	Log(LevelTrace, "I2C probe", LogBus, h.bus, LogAddress, addr)
 
-	if Logger != nil {
-		log.Printf("I2C write addr:%#x reg:%#x val:%#x\n", addr, reg, w)
+	return i2cError(h.b.Tx(uint16(addr), nil, r))
+}
+
+func (h *i2cHandle) Close() (err error) {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.b == nil {
+		return
 	}
 
-	err = b.Tx(uint16(addr), w, nil)
+	err = h.b.Close()
+	h.b = nil
+
+	if e := h.lock.Unlock(); err == nil {
+		err = e
+	}
 
 	return
 }
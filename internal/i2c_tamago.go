@@ -18,22 +18,48 @@ import (
 
 const I2CBus = 0
 
+// i2cHandle represents the i.MX6UL I2C1 controller.
+type i2cHandle struct{}
+
 func init() {
 	imx6ul.I2C1.Init()
 }
 
-func I2CRead(bus int, addr int, reg uint8, size uint) (val []byte, err error) {
+func openI2C(bus int) (handle I2CHandle, err error) {
 	if bus != I2CBus {
-		return nil, fmt.Errorf("I2C bus must be set to %d", I2CBus)
+		return nil, Classify(fmt.Errorf("I2C bus must be set to %d", I2CBus), ErrBusNotFound)
 	}
 
-	return imx6ul.I2C1.Read(uint8(addr), uint32(reg), 1, int(size))
+	return i2cHandle{}, nil
 }
 
-func I2CWrite(bus int, addr int, reg uint8, val []byte) (err error) {
-	if bus != I2CBus {
-		return fmt.Errorf("I2C bus must be set to %d", I2CBus)
+func (h i2cHandle) Read(addr int, reg uint8, size uint) (val []byte, err error) {
+	if val, err = imx6ul.I2C1.Read(uint8(addr), uint32(reg), 1, int(size)); err != nil {
+		return
 	}
 
+	Log(LevelTrace, "I2C read", LogBus, I2CBus, LogAddress, addr, LogRegister, reg, Payload(val))
+
+	return
+}
+
+func (h i2cHandle) Write(addr int, reg uint8, val []byte) (err error) {
+	Log(LevelTrace, "I2C write", LogBus, I2CBus, LogAddress, addr, LogRegister, reg, Payload(val))
+
 	return imx6ul.I2C1.Write(val, uint8(addr), uint32(reg), 1)
 }
+
+func (h i2cHandle) Probe(addr int) (err error) {
+	Log(LevelTrace, "I2C probe", LogBus, I2CBus, LogAddress, addr)
+
+	// no register address
+	_, err = imx6ul.I2C1.Read(uint8(addr), 0, 0, 1)
+
+	return
+}
+
+// Close has no effect as the controller is initialized once and never
+// released.
+func (h i2cHandle) Close() (err error) {
+	return
+}
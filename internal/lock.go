@@ -0,0 +1,23 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux tamago
+
+package armoryctl
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrBusy is returned when a bus lock is held by another process, or another
+// holder within the process, beyond the lock wait timeout.
+var ErrBusy = errors.New("bus busy")
+
+// LockTimeout is the maximum wait time for acquiring a bus lock.
+var LockTimeout = 5 * time.Second
@@ -0,0 +1,151 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux
+
+package armoryctl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// LockDir is the directory holding the advisory lock files, an empty value
+// disables locking.
+var LockDir = "/run/lock"
+
+// lock acquisition polling interval
+const lockPollInterval = 10 * time.Millisecond
+
+// Lock represents an advisory lock on a bus device, exclusive both within
+// the process and across processes.
+type Lock struct {
+	path string
+	file *os.File
+	sem  chan struct{}
+	once sync.Once
+}
+
+var (
+	lockMutex sync.Mutex
+	// per path in-process semaphores
+	lockSems = make(map[string]chan struct{})
+)
+
+// lockFile returns the lock file path for a bus device path (e.g.
+// /run/lock/armoryctl-dev-i2c-0.lock).
+func lockFile(path string) string {
+	name := strings.ReplaceAll(strings.Trim(filepath.Clean(path), "/"), "/", "-")
+	return filepath.Join(LockDir, "armoryctl-"+name+".lock")
+}
+
+// lockSem returns the in-process semaphore for the bus device at path.
+func lockSem(path string) (sem chan struct{}) {
+	lockMutex.Lock()
+	defer lockMutex.Unlock()
+
+	if sem = lockSems[path]; sem == nil {
+		sem = make(chan struct{}, 1)
+		lockSems[path] = sem
+	}
+
+	return
+}
+
+// LockPath acquires the lock for the bus device at path, waiting up to
+// timeout for other holders, within the process or in other processes
+// (advisory flock), to release it. ErrBusy is returned on timeout.
+//
+// The lock is not re-entrant, nested use of the same bus (e.g. a package
+// level driver function called while an I²C handle or BLE session is open)
+// waits for the first holder and fails with ErrBusy.
+func LockPath(path string, timeout time.Duration) (l *Lock, err error) {
+	sem := lockSem(path)
+	deadline := time.Now().Add(timeout)
+
+	select {
+	case sem <- struct{}{}:
+	default:
+		// a free lock is always acquired, regardless of the timeout
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case sem <- struct{}{}:
+		case <-timer.C:
+			return nil, fmt.Errorf("%s locked within the process, %w", path, ErrBusy)
+		}
+	}
+
+	defer func() {
+		if err != nil {
+			<-sem
+		}
+	}()
+
+	l = &Lock{
+		path: path,
+		sem:  sem,
+	}
+
+	if LockDir == "" {
+		return
+	}
+
+	// a read-only descriptor is sufficient for flock, which allows
+	// processes of different users to share the same lock file
+	f, err := os.OpenFile(lockFile(path), os.O_RDONLY|os.O_CREATE, 0644)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not open lock file, %w", err)
+	}
+
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			_ = f.Close()
+			return nil, fmt.Errorf("could not lock %s, %w", path, err)
+		}
+
+		if time.Now().After(deadline) {
+			_ = f.Close()
+			return nil, fmt.Errorf("%s locked by another process, %w", path, ErrBusy)
+		}
+
+		time.Sleep(lockPollInterval)
+	}
+
+	l.file = f
+
+	return
+}
+
+// Unlock releases the lock, further calls have no effect.
+func (l *Lock) Unlock() (err error) {
+	l.once.Do(func() {
+		// closing the descriptor releases the flock
+		if l.file != nil {
+			err = l.file.Close()
+		}
+
+		<-l.sem
+	})
+
+	return
+}
@@ -0,0 +1,116 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux
+
+package armoryctl
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func setLockDir(t *testing.T) {
+	dir := LockDir
+	LockDir = t.TempDir()
+	t.Cleanup(func() { LockDir = dir })
+}
+
+func TestLockPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		lockDir bool
+	}{
+		{"flock", true},
+		{"in-process", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setLockDir(t)
+
+			if !tt.lockDir {
+				LockDir = ""
+			}
+
+			l, err := LockPath("/dev/i2c-0", 0)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = LockPath("/dev/i2c-0", 20*time.Millisecond); !errors.Is(err, ErrBusy) {
+				t.Errorf("expected busy lock, got %v", err)
+			}
+
+			// other buses are not affected
+			o, err := LockPath("/dev/i2c-1", 0)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_ = o.Unlock()
+
+			// the lock is released to waiting holders
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				_ = l.Unlock()
+			}()
+
+			if l, err = LockPath("/dev/i2c-0", time.Second); err != nil {
+				t.Fatal(err)
+			}
+
+			// further unlocks have no effect
+			_ = l.Unlock()
+			_ = l.Unlock()
+
+			if l, err = LockPath("/dev/i2c-0", 0); err != nil {
+				t.Fatal(err)
+			}
+
+			_ = l.Unlock()
+		})
+	}
+}
+
+func TestLockPathProcess(t *testing.T) {
+	setLockDir(t)
+
+	// an independent open file description stands for another process
+	f, err := os.OpenFile(lockFile("/dev/ttymxc0"), os.O_RDONLY|os.O_CREATE, 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = f.Close() }() // make errcheck happy
+
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = LockPath("/dev/ttymxc0", 20*time.Millisecond); !errors.Is(err, ErrBusy) {
+		t.Fatalf("expected busy lock, got %v", err)
+	}
+
+	// a failed attempt releases the in-process lock
+	_ = f.Close()
+
+	l, err := LockPath("/dev/ttymxc0", 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = l.Unlock()
+}
@@ -0,0 +1,60 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package armoryctl
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+)
+
+// LevelTrace is the log level of transfer payload dumps, below
+// slog.LevelDebug.
+const LevelTrace = slog.LevelDebug - 4
+
+// Log attribute keys, shared by all events for consistent filtering.
+const (
+	LogDevice   = "device"
+	LogBus      = "bus"
+	LogAddress  = "address"
+	LogRegister = "register"
+	LogOpcode   = "opcode"
+	LogDuration = "duration"
+	LogPayload  = "payload"
+)
+
+// LogEnabled returns whether events at the argument level are logged.
+func LogEnabled(level slog.Level) bool {
+	return Logger != nil && Logger.Enabled(context.Background(), level)
+}
+
+// Log emits an event, with the argument key-value attributes, on the
+// configured Logger.
+func Log(level slog.Level, msg string, args ...any) {
+	if !LogEnabled(level) {
+		return
+	}
+
+	Logger.Log(context.Background(), level, msg, args...)
+}
+
+// Hex returns a log value rendering b as hexadecimal string.
+func Hex(b []byte) slog.Value {
+	return slog.StringValue(hex.EncodeToString(b))
+}
+
+// Payload returns the payload attribute for b, which is only populated at
+// trace level.
+func Payload(b []byte) slog.Attr {
+	if !LogEnabled(LevelTrace) {
+		return slog.Attr{}
+	}
+
+	return slog.Attr{Key: LogPayload, Value: Hex(b)}
+}
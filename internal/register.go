@@ -0,0 +1,353 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux tamago
+
+package armoryctl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Access represents a register, or register field, access mode.
+type Access int
+
+// Access modes, fields inherit the access mode of their register unless set.
+const (
+	ReadWrite Access = iota
+	ReadOnly
+	WriteOnly
+	// bits set to 1 by the device, cleared by writing 1
+	WriteOneToClear
+)
+
+func (a Access) String() string {
+	switch a {
+	case ReadOnly:
+		return "RO"
+	case WriteOnly:
+		return "WO"
+	case WriteOneToClear:
+		return "W1C"
+	default:
+		return "RW"
+	}
+}
+
+// Field represents a bit field of a single byte register.
+type Field struct {
+	// Name is the field name, as found in the datasheet.
+	Name string
+	// Shift is the position of the field least significant bit.
+	Shift uint
+	// Width is the field size in bits.
+	Width uint
+	// Access is the field access mode, ReadWrite fields inherit the
+	// register access mode.
+	Access Access
+	// Values maps field values to their meaning, when not empty only
+	// listed values can be written.
+	Values map[byte]string
+}
+
+// Mask returns the field bit mask.
+func (f *Field) Mask() byte {
+	return byte((1<<f.Width)-1) << f.Shift
+}
+
+// Decode extracts the field value from a register value.
+func (f *Field) Decode(reg byte) byte {
+	return (reg & f.Mask()) >> f.Shift
+}
+
+// Encode returns the register value with the field set to val, val is
+// checked against the field width and allowed values.
+func (f *Field) Encode(reg byte, val byte) (byte, error) {
+	if f.Access == ReadOnly {
+		return reg, fmt.Errorf("field %s is read-only", f.Name)
+	}
+
+	if val > f.Mask()>>f.Shift {
+		return reg, fmt.Errorf("value %#x exceeds field %s width", val, f.Name)
+	}
+
+	if len(f.Values) > 0 {
+		if _, ok := f.Values[val]; !ok {
+			return reg, fmt.Errorf("value %#x not allowed for field %s", val, f.Name)
+		}
+	}
+
+	return reg&^f.Mask() | val<<f.Shift, nil
+}
+
+// Format returns the field value followed, when known, by its meaning.
+func (f *Field) Format(val byte) string {
+	if s, ok := f.Values[val]; ok {
+		return fmt.Sprintf("%#x(%q)", val, s)
+	}
+
+	return fmt.Sprintf("%#x", val)
+}
+
+// MismatchError is returned when a register read back after a write does not
+// hold the written value.
+type MismatchError struct {
+	// I²C slave address
+	Address int
+	// Register address
+	Register uint8
+	// Mask of the compared bits
+	Mask byte
+	// Written value
+	Want byte
+	// Read back value
+	Got byte
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("register %#x of slave %#x readback mismatch (want %#x, got %#x, mask %#x)",
+		e.Register, e.Address, e.Want&e.Mask, e.Got&e.Mask, e.Mask)
+}
+
+// UpdateRegister performs a read-modify-write of a single byte register,
+// changing only the bits set in mask to the corresponding val bits.
+//
+// The w1c argument holds the write-1-to-clear bits (see WriteOneToClear),
+// which are written as 0 when not set in mask so that pending flags are not
+// cleared by writing back their read value.
+//
+// When verify is true the register is read back and a *MismatchError is
+// returned if the masked bits, excluding write-1-to-clear ones, do not match.
+func UpdateRegister(bus I2C, addr int, reg uint8, mask byte, val byte, w1c byte, verify bool) (err error) {
+	cur, err := bus.Read(addr, reg, 1)
+
+	if err != nil {
+		return
+	}
+
+	want := (cur[0]&^mask | val&mask) &^ (w1c &^ mask)
+
+	if err = bus.Write(addr, reg, []byte{want}); err != nil {
+		return
+	}
+
+	// write-1-to-clear bits do not read back as written
+	mask &^= w1c
+
+	if !verify || mask == 0 {
+		return
+	}
+
+	got, err := bus.Read(addr, reg, 1)
+
+	if err != nil {
+		return
+	}
+
+	if got[0]&mask != want&mask {
+		return &MismatchError{
+			Address:  addr,
+			Register: reg,
+			Mask:     mask,
+			Want:     want,
+			Got:      got[0],
+		}
+	}
+
+	return
+}
+
+// Register represents a device register.
+type Register struct {
+	// Name is the register name, as found in the datasheet.
+	Name string
+	// Address is the register address.
+	Address uint8
+	// Size is the register size in bytes (1 when zero), fields are only
+	// supported on single byte registers.
+	Size uint
+	// Access is the register access mode.
+	Access Access
+	// Fields holds the register bit fields.
+	Fields []*Field
+}
+
+func (r *Register) size() uint {
+	if r.Size == 0 {
+		return 1
+	}
+
+	return r.Size
+}
+
+// Field returns the named register field.
+func (r *Register) Field(name string) (*Field, error) {
+	for _, f := range r.Fields {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+
+	return nil, fmt.Errorf("register %s has no field %s", r.Name, name)
+}
+
+// Read reads the register from the slave at address addr.
+func (r *Register) Read(bus I2C, addr int) (val []byte, err error) {
+	if r.Access == WriteOnly {
+		return nil, fmt.Errorf("register %s is write-only", r.Name)
+	}
+
+	return bus.Read(addr, r.Address, r.size())
+}
+
+// Write writes the register of the slave at address addr.
+func (r *Register) Write(bus I2C, addr int, val []byte) (err error) {
+	if r.Access == ReadOnly {
+		return fmt.Errorf("register %s is read-only", r.Name)
+	}
+
+	if uint(len(val)) != r.size() {
+		return fmt.Errorf("register %s size is %d bytes", r.Name, r.size())
+	}
+
+	return bus.Write(addr, r.Address, val)
+}
+
+// W1C returns the mask of the register write-1-to-clear bits.
+func (r *Register) W1C() (mask byte) {
+	if r.Access == WriteOneToClear {
+		mask = 0xff
+	}
+
+	// fields inherit the register access mode unless set
+	for _, f := range r.Fields {
+		if f.Access == WriteOneToClear {
+			mask |= f.Mask()
+		} else if f.Access != ReadWrite {
+			mask &^= f.Mask()
+		}
+	}
+
+	return
+}
+
+// Update performs a read-modify-write of the register (see UpdateRegister),
+// write-1-to-clear bits not set in mask are left pending.
+func (r *Register) Update(bus I2C, addr int, mask byte, val byte, verify bool) (err error) {
+	if r.Access != ReadWrite && r.Access != WriteOneToClear {
+		return fmt.Errorf("register %s is not writable", r.Name)
+	}
+
+	if r.size() != 1 {
+		return fmt.Errorf("register %s is not a single byte register", r.Name)
+	}
+
+	return UpdateRegister(bus, addr, r.Address, mask, val, r.W1C(), verify)
+}
+
+// Decode returns a description of the register value, listing each field.
+func (r *Register) Decode(val []byte) string {
+	var s strings.Builder
+
+	fmt.Fprintf(&s, "%s(%#.2x):%#x", r.Name, r.Address, val)
+
+	if len(val) != 1 {
+		return s.String()
+	}
+
+	for _, f := range r.Fields {
+		fmt.Fprintf(&s, " %s:%s", f.Name, f.Format(f.Decode(val[0])))
+	}
+
+	return s.String()
+}
+
+// RegisterMap represents the register set of a device.
+type RegisterMap []*Register
+
+// Register returns the named register.
+func (m RegisterMap) Register(name string) (*Register, error) {
+	for _, r := range m {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown register %s", name)
+}
+
+// ReadField reads a register and extracts the named field value.
+func (m RegisterMap) ReadField(bus I2C, addr int, reg string, field string) (val byte, err error) {
+	r, err := m.Register(reg)
+
+	if err != nil {
+		return
+	}
+
+	f, err := r.Field(field)
+
+	if err != nil {
+		return
+	}
+
+	v, err := r.Read(bus, addr)
+
+	if err != nil {
+		return
+	}
+
+	return f.Decode(v[0]), nil
+}
+
+// WriteField sets the named register field to val, leaving all other bits
+// unchanged (see Register.Update), val is checked against the field width
+// and allowed values.
+func (m RegisterMap) WriteField(bus I2C, addr int, reg string, field string, val byte, verify bool) (err error) {
+	r, err := m.Register(reg)
+
+	if err != nil {
+		return
+	}
+
+	f, err := r.Field(field)
+
+	if err != nil {
+		return
+	}
+
+	v, err := f.Encode(0, val)
+
+	if err != nil {
+		return
+	}
+
+	return r.Update(bus, addr, f.Mask(), v, verify)
+}
+
+// Dump reads all readable registers and returns their decoded values, one
+// register per line.
+func (m RegisterMap) Dump(bus I2C, addr int) (res string, err error) {
+	var lines []string
+
+	for _, r := range m {
+		if r.Access == WriteOnly {
+			continue
+		}
+
+		val, err := r.Read(bus, addr)
+
+		if err != nil {
+			return "", err
+		}
+
+		lines = append(lines, r.Decode(val))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,238 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux tamago
+
+package armoryctl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var testRegisters = RegisterMap{
+	{Name: "ID", Address: 0x00, Size: 2, Access: ReadOnly},
+	{Name: "CTRL", Address: 0x02, Fields: []*Field{
+		{Name: "EN", Shift: 0, Width: 1},
+		{Name: "MODE", Shift: 1, Width: 2, Values: map[byte]string{0: "off", 1: "slow", 2: "fast"}},
+		{Name: "REV", Shift: 4, Width: 4, Access: ReadOnly},
+	}},
+	{Name: "CMD", Address: 0x03, Access: WriteOnly},
+}
+
+func TestFieldEncode(t *testing.T) {
+	ctrl, _ := testRegisters.Register("CTRL")
+
+	tests := []struct {
+		field string
+		reg   byte
+		val   byte
+		want  byte
+		err   string
+	}{
+		{"EN", 0x00, 1, 0x01, ""},
+		{"EN", 0xff, 0, 0xfe, ""},
+		{"EN", 0x00, 2, 0x00, "exceeds"},
+		{"MODE", 0xf1, 2, 0xf5, ""},
+		{"MODE", 0x00, 3, 0x00, "not allowed"},
+		{"REV", 0x00, 1, 0x00, "read-only"},
+	}
+
+	for _, tt := range tests {
+		f, err := ctrl.Field(tt.field)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := f.Encode(tt.reg, tt.val)
+
+		switch {
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s.Encode(%#x, %#x): expected %q error, got %v", tt.field, tt.reg, tt.val, tt.err, err)
+		case tt.err == "" && err != nil:
+			t.Errorf("%s.Encode(%#x, %#x): %v", tt.field, tt.reg, tt.val, err)
+		case got != tt.want:
+			t.Errorf("%s.Encode(%#x, %#x) = %#x, want %#x", tt.field, tt.reg, tt.val, got, tt.want)
+		}
+	}
+}
+
+func TestFieldDecode(t *testing.T) {
+	ctrl, _ := testRegisters.Register("CTRL")
+
+	tests := []struct {
+		field string
+		reg   byte
+		mask  byte
+		val   byte
+		str   string
+	}{
+		{"EN", 0xa5, 0x01, 1, "0x1"},
+		{"MODE", 0xa5, 0x06, 2, `0x2("fast")`},
+		{"MODE", 0xa7, 0x06, 3, "0x3"},
+		{"REV", 0xa5, 0xf0, 0xa, "0xa"},
+	}
+
+	for _, tt := range tests {
+		f, _ := ctrl.Field(tt.field)
+
+		if m := f.Mask(); m != tt.mask {
+			t.Errorf("%s.Mask() = %#x, want %#x", tt.field, m, tt.mask)
+		}
+
+		if v := f.Decode(tt.reg); v != tt.val {
+			t.Errorf("%s.Decode(%#x) = %#x, want %#x", tt.field, tt.reg, v, tt.val)
+		}
+
+		if s := f.Format(tt.val); s != tt.str {
+			t.Errorf("%s.Format(%#x) = %s, want %s", tt.field, tt.val, s, tt.str)
+		}
+	}
+
+	if _, err := ctrl.Field("NONE"); err == nil {
+		t.Error("expected unknown field error")
+	}
+}
+
+func TestRegisterMap(t *testing.T) {
+	bus := &testI2C{}
+	bus.regs[0x00] = 0x12
+	bus.regs[0x01] = 0x34
+	bus.regs[0x02] = 0xa0
+
+	if err := testRegisters.WriteField(bus, 0x10, "CTRL", "MODE", 1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testRegisters.WriteField(bus, 0x10, "CTRL", "EN", 1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if bus.regs[0x02] != 0xa3 {
+		t.Errorf("unexpected CTRL value %#x", bus.regs[0x02])
+	}
+
+	if v, err := testRegisters.ReadField(bus, 0x10, "CTRL", "REV"); err != nil || v != 0xa {
+		t.Errorf("unexpected REV value %#x (%v)", v, err)
+	}
+
+	for _, tt := range []struct{ reg, field string }{{"NONE", "EN"}, {"CTRL", "NONE"}, {"CTRL", "REV"}} {
+		if err := testRegisters.WriteField(bus, 0x10, tt.reg, tt.field, 0, false); err == nil {
+			t.Errorf("expected %s.%s write error", tt.reg, tt.field)
+		}
+	}
+
+	id, _ := testRegisters.Register("ID")
+
+	if err := id.Write(bus, 0x10, []byte{0, 0}); err == nil {
+		t.Error("expected read-only register write error")
+	}
+
+	cmd, _ := testRegisters.Register("CMD")
+
+	if _, err := cmd.Read(bus, 0x10); err == nil {
+		t.Error("expected write-only register read error")
+	}
+
+	if err := cmd.Write(bus, 0x10, []byte{0, 0}); err == nil {
+		t.Error("expected register size error")
+	}
+
+	res, err := testRegisters.Dump(bus, 0x10)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// write-only registers are skipped
+	want := "ID(0x00):0x1234\n" +
+		`CTRL(0x02):0xa3 EN:0x1 MODE:0x1("slow") REV:0xa`
+
+	if res != want {
+		t.Errorf("unexpected dump:\n%s\nwant:\n%s", res, want)
+	}
+}
+
+// testRegister represents a single byte register with read-only and
+// write-1-to-clear bits.
+type testRegister struct {
+	val byte
+	ro  byte
+	w1c byte
+}
+
+func (r *testRegister) Read(addr int, reg uint8, size uint) (val []byte, err error) {
+	return []byte{r.val}, nil
+}
+
+func (r *testRegister) Write(addr int, reg uint8, val []byte) (err error) {
+	keep := r.ro | r.w1c
+	r.val = (r.val&keep | val[0]&^keep) &^ (val[0] & r.w1c)
+
+	return
+}
+
+func TestUpdateRegister(t *testing.T) {
+	tests := []struct {
+		name     string
+		reg      testRegister
+		mask     byte
+		val      byte
+		w1c      byte
+		want     byte
+		mismatch byte
+	}{
+		{"update", testRegister{val: 0xf0}, 0x0f, 0x05, 0x00, 0xf5, 0},
+		{"read-only", testRegister{val: 0xf0, ro: 0x01}, 0x0f, 0x05, 0x00, 0xf4, 0x0f},
+		{"pending", testRegister{val: 0x10, w1c: 0x10}, 0x03, 0x02, 0x10, 0x12, 0},
+		{"cleared", testRegister{val: 0x10, w1c: 0x10}, 0x03, 0x02, 0x00, 0x02, 0},
+		{"clear", testRegister{val: 0x13, w1c: 0x10}, 0x10, 0x10, 0x10, 0x03, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := tt.reg
+			err := UpdateRegister(&reg, 0x10, 0x01, tt.mask, tt.val, tt.w1c, true)
+
+			var e *MismatchError
+
+			switch {
+			case tt.mismatch != 0 && !errors.As(err, &e):
+				t.Fatalf("expected mismatch error, got %v", err)
+			case tt.mismatch != 0 && e.Mask != tt.mismatch:
+				t.Errorf("unexpected mismatch mask %#x, want %#x", e.Mask, tt.mismatch)
+			case tt.mismatch == 0 && err != nil:
+				t.Fatal(err)
+			}
+
+			if reg.val != tt.want {
+				t.Errorf("unexpected register value %#x, want %#x", reg.val, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterW1C(t *testing.T) {
+	tests := []struct {
+		reg  *Register
+		want byte
+	}{
+		{&Register{}, 0x00},
+		{&Register{Access: WriteOneToClear}, 0xff},
+		{&Register{Fields: []*Field{{Shift: 4, Width: 1, Access: WriteOneToClear}, {Shift: 0, Width: 2}}}, 0x10},
+		{&Register{Access: WriteOneToClear, Fields: []*Field{{Shift: 7, Width: 1, Access: ReadOnly}, {Shift: 0, Width: 2}}}, 0x7f},
+	}
+
+	for i, tt := range tests {
+		if m := tt.reg.W1C(); m != tt.want {
+			t.Errorf("%d: unexpected W1C mask %#x, want %#x", i, m, tt.want)
+		}
+	}
+}
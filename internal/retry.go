@@ -0,0 +1,103 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux tamago
+
+package armoryctl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// RetryPolicy represents the retry policy for failed operations.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts, values lower than 2
+	// disable retries.
+	Attempts int
+	// Backoff is the wait time before the first retry, doubled before
+	// each further retry.
+	Backoff time.Duration
+	// Retryable returns whether an error is retryable, IsTransient is used
+	// when nil.
+	Retryable func(error) bool
+}
+
+// Retry is the global retry policy, used by drivers without a policy of
+// their own, retries are disabled when nil.
+var Retry = &RetryPolicy{
+	Attempts: 3,
+	Backoff:  10 * time.Millisecond,
+}
+
+// Do runs f until it succeeds, it fails with a non retryable error, the
+// attempts are exhausted or ctx is done. A nil policy results in the global
+// one being used.
+//
+// When ctx is done while waiting to retry, the returned error wraps both
+// ctx.Err() and the last error returned by f.
+func (p *RetryPolicy) Do(ctx context.Context, f func() error) (err error) {
+	if p == nil {
+		p = Retry
+	}
+
+	if p == nil {
+		return f()
+	}
+
+	retryable := p.Retryable
+
+	if retryable == nil {
+		retryable = IsTransient
+	}
+
+	backoff := p.Backoff
+
+	for attempt := 1; ; attempt++ {
+		if err = f(); err == nil || attempt >= p.Attempts || !retryable(err) {
+			return
+		}
+
+		Log(slog.LevelWarn, "retrying", "attempt", attempt, "backoff", backoff, "error", err)
+
+		if ctxErr := Sleep(ctx, backoff); ctxErr != nil {
+			return fmt.Errorf("%w, %w", ctxErr, err)
+		}
+
+		backoff *= 2
+	}
+}
+
+// retryI2C represents an I²C transport retrying failed transfers.
+type retryI2C struct {
+	bus    I2C
+	policy *RetryPolicy
+}
+
+// WithRetry returns an I²C transport retrying failed transfers on bus
+// according to policy (the global one when nil).
+func WithRetry(bus I2C, policy *RetryPolicy) I2C {
+	return &retryI2C{bus: bus, policy: policy}
+}
+
+func (r *retryI2C) Read(addr int, reg uint8, size uint) (val []byte, err error) {
+	err = r.policy.Do(context.Background(), func() (err error) {
+		val, err = r.bus.Read(addr, reg, size)
+		return
+	})
+
+	return
+}
+
+func (r *retryI2C) Write(addr int, reg uint8, val []byte) (err error) {
+	return r.policy.Do(context.Background(), func() error {
+		return r.bus.Write(addr, reg, val)
+	})
+}
@@ -0,0 +1,158 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux tamago
+
+package armoryctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	nack := Classify(errors.New("no ack"), ErrNACK)
+	status := Classify(errors.New("status"), ErrDeviceStatus)
+	errTest := errors.New("test")
+
+	tests := []struct {
+		name     string
+		policy   *RetryPolicy
+		errs     []error
+		calls    int
+		want     error
+		wantLast bool
+	}{
+		{"success", &RetryPolicy{Attempts: 3}, []error{nil}, 1, nil, false},
+		{"transient", &RetryPolicy{Attempts: 3}, []error{nack, nack, nil}, 3, nil, false},
+		{"exhausted", &RetryPolicy{Attempts: 3}, []error{nack, nack, nack, nil}, 3, ErrNACK, true},
+		{"permanent", &RetryPolicy{Attempts: 3}, []error{status, nil}, 1, ErrDeviceStatus, true},
+		{"disabled", &RetryPolicy{Attempts: 1}, []error{nack, nil}, 1, ErrNACK, true},
+		{"retryable", &RetryPolicy{Attempts: 3, Retryable: func(err error) bool { return err == errTest }}, []error{errTest, nack, nil}, 2, ErrNACK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+
+			err := tt.policy.Do(context.Background(), func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+
+			if calls != tt.calls {
+				t.Errorf("unexpected calls %d, want %d", calls, tt.calls)
+			}
+
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Errorf("unexpected error %v, want %v", err, tt.want)
+			}
+
+			if tt.wantLast && err != tt.errs[calls-1] {
+				t.Errorf("unexpected error %v, want the last returned one", err)
+			}
+		})
+	}
+}
+
+func TestRetryCancel(t *testing.T) {
+	nack := Classify(errors.New("no ack"), ErrNACK)
+	policy := &RetryPolicy{Attempts: 3, Backoff: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := policy.Do(ctx, func() error { return nack })
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context error, got %v", err)
+	}
+
+	if !errors.Is(err, ErrNACK) {
+		t.Errorf("expected last error, got %v", err)
+	}
+}
+
+func TestRetryGlobal(t *testing.T) {
+	retry := Retry
+	t.Cleanup(func() { Retry = retry })
+
+	nack := Classify(errors.New("no ack"), ErrNACK)
+
+	for _, tt := range []struct {
+		global *RetryPolicy
+		calls  int
+	}{
+		{&RetryPolicy{Attempts: 2}, 2},
+		{nil, 1},
+	} {
+		Retry = tt.global
+
+		var calls int
+		var policy *RetryPolicy
+
+		_ = policy.Do(context.Background(), func() error {
+			calls++
+			return nack
+		})
+
+		if calls != tt.calls {
+			t.Errorf("unexpected calls %d with global policy %+v, want %d", calls, tt.global, tt.calls)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	base := errors.New("transfer error")
+
+	tests := []struct {
+		err       error
+		class     error
+		transient bool
+	}{
+		{Classify(base, ErrNACK), ErrNACK, true},
+		{Classify(base, ErrChecksum), ErrChecksum, true},
+		{Classify(base, ErrTimeout), ErrTimeout, true},
+		{Classify(base, ErrDeviceStatus), ErrDeviceStatus, false},
+		{Classify(base, ErrDeviceMissing), ErrDeviceMissing, false},
+		{fmt.Errorf("wrapped, %w", Classify(base, ErrNACK)), ErrNACK, true},
+		{ErrBusNotFound, ErrDeviceMissing, false},
+	}
+
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.class) {
+			t.Errorf("%v does not match %v", tt.err, tt.class)
+		}
+
+		if tt.err != ErrBusNotFound && !errors.Is(tt.err, base) {
+			t.Errorf("%v does not match its cause", tt.err)
+		}
+
+		if IsTransient(tt.err) != tt.transient {
+			t.Errorf("IsTransient(%v) != %v", tt.err, tt.transient)
+		}
+	}
+
+	// the message is preserved and classes are not stacked
+	err := Classify(base, ErrNACK)
+
+	if err.Error() != base.Error() {
+		t.Errorf("unexpected message %q", err)
+	}
+
+	if Classify(err, ErrNACK) != err {
+		t.Error("error classified twice")
+	}
+
+	if Classify(nil, ErrNACK) != nil {
+		t.Error("nil error classified")
+	}
+}
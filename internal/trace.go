@@ -0,0 +1,405 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux tamago
+
+package armoryctl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Trace event types
+const (
+	TraceI2C  = "i2c"
+	TraceUART = "uart"
+)
+
+// Recording, when set, receives all I²C transfers and UART exchanges
+// performed through the system or backend transports.
+var Recording *Recorder
+
+// HexBytes represents a byte slice, encoded as hexadecimal string in traces.
+type HexBytes []byte
+
+func (b HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(b))
+}
+
+func (b *HexBytes) UnmarshalJSON(data []byte) (err error) {
+	var s string
+
+	if err = json.Unmarshal(data, &s); err != nil {
+		return
+	}
+
+	*b, err = hex.DecodeString(s)
+
+	return
+}
+
+// TraceEvent represents a single recorded I²C transfer or UART exchange.
+//
+// I²C transfers are recorded as the bytes written (register address followed
+// by any value) and the bytes read, if any. Address probes are recorded as a
+// single byte read with no bytes written.
+type TraceEvent struct {
+	// Type is either TraceI2C or TraceUART.
+	Type string `json:"type"`
+	// Time is the transfer start time.
+	Time time.Time `json:"time"`
+	// Duration is the transfer duration.
+	Duration time.Duration `json:"duration"`
+
+	// Bus is the I²C bus number.
+	Bus int `json:"bus,omitempty"`
+	// Address is the I²C slave address.
+	Address int `json:"addr,omitempty"`
+	// Write holds the I²C bytes written.
+	Write HexBytes `json:"write,omitempty"`
+	// Read holds the I²C bytes read.
+	Read HexBytes `json:"read,omitempty"`
+	// Size is the I²C requested read size.
+	Size uint `json:"size,omitempty"`
+
+	// Path is the UART device path.
+	Path string `json:"path,omitempty"`
+	// Speed is the UART baud rate.
+	Speed int `json:"speed,omitempty"`
+	// Command is the UART AT command written.
+	Command string `json:"cmd,omitempty"`
+	// Response is the UART AT command response.
+	Response *ATResponse `json:"res,omitempty"`
+
+	// Error is the transfer error, if any.
+	Error string `json:"error,omitempty"`
+	// Class is the transfer error class (see TraceErrorClass), if any.
+	Class string `json:"class,omitempty"`
+}
+
+// traceClasses holds the error classes preserved in traces, by name.
+var traceClasses = []struct {
+	name  string
+	class error
+}{
+	{"device_missing", ErrDeviceMissing},
+	{"nack", ErrNACK},
+	{"checksum", ErrChecksum},
+	{"device_status", ErrDeviceStatus},
+	{"timeout", ErrTimeout},
+}
+
+// TraceErrorClass returns the name of the error class matched by err, or an
+// empty string if none.
+func TraceErrorClass(err error) string {
+	for _, c := range traceClasses {
+		if errors.Is(err, c.class) {
+			return c.name
+		}
+	}
+
+	return ""
+}
+
+// setError records err, along with its class, in the trace event.
+func (ev *TraceEvent) setError(err error) {
+	if err == nil {
+		return
+	}
+
+	ev.Error = err.Error()
+	ev.Class = TraceErrorClass(err)
+}
+
+// err returns the recorded trace event error, restoring its class so that
+// replayed errors are handled like the recorded ones (e.g. retried).
+func (ev *TraceEvent) err() (err error) {
+	if ev.Error == "" {
+		return
+	}
+
+	err = errors.New(ev.Error)
+
+	for _, c := range traceClasses {
+		if ev.Class == c.name {
+			return Classify(err, c.class)
+		}
+	}
+
+	return
+}
+
+// Recorder writes trace events, one JSON object per line.
+type Recorder struct {
+	sync.Mutex
+
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder returns a recorder writing trace events to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		enc: json.NewEncoder(w),
+	}
+}
+
+// Record writes a trace event.
+func (r *Recorder) Record(ev *TraceEvent) (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.err == nil {
+		r.err = r.enc.Encode(ev)
+	}
+
+	return r.err
+}
+
+// Err returns the first error encountered while writing trace events.
+func (r *Recorder) Err() (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.err
+}
+
+func (r *Recorder) i2c(bus int, addr int, w []byte, res []byte, size uint, err error, start time.Time) {
+	ev := &TraceEvent{
+		Type:     TraceI2C,
+		Time:     start,
+		Duration: time.Since(start),
+		Bus:      bus,
+		Address:  addr,
+		Write:    w,
+		Read:     res,
+		Size:     size,
+	}
+
+	ev.setError(err)
+
+	_ = r.Record(ev)
+}
+
+// UART records an AT command exchange, started at the argument time, with
+// the serial device at path.
+func (r *Recorder) UART(path string, speed int, cmd string, res *ATResponse, err error, start time.Time) {
+	ev := &TraceEvent{
+		Type:     TraceUART,
+		Time:     start,
+		Duration: time.Since(start),
+		Path:     path,
+		Speed:    speed,
+		Command:  cmd,
+		Response: res,
+	}
+
+	ev.setError(err)
+
+	_ = r.Record(ev)
+}
+
+// i2cRecorder represents an I²C handle with recorded transfers.
+type i2cRecorder struct {
+	I2CHandle
+
+	rec *Recorder
+	bus int
+}
+
+func (r *Recorder) i2cHandle(bus int, handle I2CHandle) I2CHandle {
+	return &i2cRecorder{
+		I2CHandle: handle,
+		rec:       r,
+		bus:       bus,
+	}
+}
+
+func (h *i2cRecorder) Read(addr int, reg uint8, size uint) (val []byte, err error) {
+	start := time.Now()
+	val, err = h.I2CHandle.Read(addr, reg, size)
+	h.rec.i2c(h.bus, addr, []byte{reg}, val, size, err, start)
+
+	return
+}
+
+func (h *i2cRecorder) Probe(addr int) (err error) {
+	start := time.Now()
+	err = ProbeI2C(h.I2CHandle, addr)
+	h.rec.i2c(h.bus, addr, nil, nil, 1, err, start)
+
+	return
+}
+
+func (h *i2cRecorder) Write(addr int, reg uint8, val []byte) (err error) {
+	start := time.Now()
+	err = h.I2CHandle.Write(addr, reg, val)
+	h.rec.i2c(h.bus, addr, append([]byte{reg}, val...), nil, 0, err, start)
+
+	return
+}
+
+// Replayer feeds recorded trace events back, in order, in place of the I²C
+// and UART transports. Each transfer must match the next recorded one,
+// otherwise an error is returned.
+type Replayer struct {
+	sync.Mutex
+
+	events []*TraceEvent
+	next   int
+}
+
+// NewReplayer returns a replayer for the trace events read from r.
+func NewReplayer(r io.Reader) (p *Replayer, err error) {
+	p = &Replayer{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		ev := &TraceEvent{}
+
+		if err = json.Unmarshal(scanner.Bytes(), ev); err != nil {
+			return nil, fmt.Errorf("invalid trace event at line %d, %v", line, err)
+		}
+
+		p.events = append(p.events, ev)
+	}
+
+	return p, scanner.Err()
+}
+
+// Buses returns the I²C bus numbers present in the trace.
+func (p *Replayer) Buses() (buses []int) {
+	seen := make(map[int]bool)
+
+	for _, ev := range p.events {
+		if ev.Type == TraceI2C && !seen[ev.Bus] {
+			seen[ev.Bus] = true
+			buses = append(buses, ev.Bus)
+		}
+	}
+
+	return
+}
+
+// Remaining returns the number of trace events not yet replayed.
+func (p *Replayer) Remaining() int {
+	p.Lock()
+	defer p.Unlock()
+
+	return len(p.events) - p.next
+}
+
+func (p *Replayer) pop(match func(ev *TraceEvent) bool, desc string) (ev *TraceEvent, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.next >= len(p.events) {
+		return nil, fmt.Errorf("trace exhausted, unexpected %s", desc)
+	}
+
+	ev = p.events[p.next]
+
+	if !match(ev) {
+		return nil, fmt.Errorf("trace mismatch at event %d, unexpected %s", p.next+1, desc)
+	}
+
+	p.next++
+
+	return ev, ev.err()
+}
+
+// replayI2C represents an I²C bus fed by a trace.
+type replayI2C struct {
+	p   *Replayer
+	bus int
+}
+
+// I2C returns a transport replaying the numbered bus trace events.
+func (p *Replayer) I2C(bus int) I2C {
+	return &replayI2C{p: p, bus: bus}
+}
+
+func (b *replayI2C) Read(addr int, reg uint8, size uint) (val []byte, err error) {
+	w := []byte{reg}
+	desc := fmt.Sprintf("I2C read bus:%d addr:%#x reg:%#x size:%d", b.bus, addr, reg, size)
+
+	ev, err := b.p.pop(func(ev *TraceEvent) bool {
+		return ev.Type == TraceI2C && ev.Bus == b.bus && ev.Address == addr &&
+			ev.Size == size && bytes.Equal(ev.Write, w)
+	}, desc)
+
+	if ev != nil {
+		val = ev.Read
+	}
+
+	return
+}
+
+func (b *replayI2C) Write(addr int, reg uint8, val []byte) (err error) {
+	w := append([]byte{reg}, val...)
+	desc := fmt.Sprintf("I2C write bus:%d addr:%#x val:%#x", b.bus, addr, w)
+
+	_, err = b.p.pop(func(ev *TraceEvent) bool {
+		return ev.Type == TraceI2C && ev.Bus == b.bus && ev.Address == addr &&
+			ev.Size == 0 && bytes.Equal(ev.Write, w)
+	}, desc)
+
+	return
+}
+
+// Probe replays a recorded read-only transaction, recorded as a single byte
+// read with no bytes written.
+func (b *replayI2C) Probe(addr int) (err error) {
+	desc := fmt.Sprintf("I2C probe bus:%d addr:%#x", b.bus, addr)
+
+	_, err = b.p.pop(func(ev *TraceEvent) bool {
+		return ev.Type == TraceI2C && ev.Bus == b.bus && ev.Address == addr &&
+			ev.Size == 1 && len(ev.Write) == 0
+	}, desc)
+
+	return
+}
+
+// Command returns the next recorded UART AT command response, it implements
+// the UART interface.
+func (p *Replayer) Command(ctx context.Context, path string, speed int, cmd string, timeout time.Duration) (res *ATResponse, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	desc := fmt.Sprintf("UART command %q", cmd)
+
+	ev, err := p.pop(func(ev *TraceEvent) bool {
+		return ev.Type == TraceUART && ev.Command == cmd
+	}, desc)
+
+	if ev != nil {
+		res = ev.Response
+	}
+
+	// restore the error type of failed commands
+	if res != nil && res.Result != ATResultOK {
+		err = NewCommandError(cmd, res.Result)
+	}
+
+	return
+}
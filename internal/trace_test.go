@@ -0,0 +1,190 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux tamago
+
+package armoryctl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testI2C represents an I²C slave register map, with a missing slave at
+// address 0x00.
+type testI2C struct {
+	regs [256]byte
+}
+
+func (b *testI2C) Read(addr int, reg uint8, size uint) (val []byte, err error) {
+	if addr == 0x00 {
+		return nil, Classify(errors.New("no device"), ErrNACK)
+	}
+
+	return append([]byte{}, b.regs[int(reg):int(reg)+int(size)]...), nil
+}
+
+func (b *testI2C) Write(addr int, reg uint8, val []byte) (err error) {
+	if addr == 0x00 {
+		return Classify(errors.New("no device"), ErrNACK)
+	}
+
+	copy(b.regs[reg:], val)
+
+	return
+}
+
+func (b *testI2C) Close() (err error) {
+	return
+}
+
+func TestTraceRoundTrip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	rec := NewRecorder(buf)
+	h := rec.i2cHandle(1, &testI2C{})
+
+	if err := h.Write(0x10, 0x02, []byte{0xaa, 0xbb}); err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := h.Read(0x10, 0x02, 2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = h.Read(0x00, 0x00, 1); !errors.Is(err, ErrNACK) {
+		t.Fatalf("expected NACK, got %v", err)
+	}
+
+	res := &ATResponse{Lines: []string{"+UBTLN:\"armory\""}, Result: ATResultOK}
+	rec.UART("/dev/ttymxc0", 115200, "AT+UBTLN?", res, nil, time.Now())
+
+	if err = rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewReplayer(buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b := p.Buses(); len(b) != 1 || b[0] != 1 {
+		t.Errorf("unexpected trace buses %v", b)
+	}
+
+	bus := p.I2C(1)
+
+	if err = bus.Write(0x10, 0x02, []byte{0xaa, 0xbb}); err != nil {
+		t.Error(err)
+	}
+
+	if v, err := bus.Read(0x10, 0x02, 2); err != nil || !bytes.Equal(v, val) {
+		t.Errorf("unexpected replayed read %x (%v), want %x", v, err, val)
+	}
+
+	// replayed errors preserve their class
+	if _, err = bus.Read(0x00, 0x00, 1); !errors.Is(err, ErrNACK) {
+		t.Errorf("expected replayed NACK, got %v", err)
+	}
+
+	r, err := p.Command(context.Background(), "/dev/ttymxc0", 115200, "AT+UBTLN?", time.Second)
+
+	if err != nil || r == nil || r.Lines[0] != res.Lines[0] {
+		t.Errorf("unexpected replayed response %+v (%v)", r, err)
+	}
+
+	if n := p.Remaining(); n != 0 {
+		t.Errorf("%d trace events left", n)
+	}
+
+	if _, err = bus.Read(0x10, 0x02, 2); err == nil || !strings.Contains(err.Error(), "exhausted") {
+		t.Errorf("expected exhausted trace, got %v", err)
+	}
+}
+
+func TestTraceMismatch(t *testing.T) {
+	trace := `{"type":"i2c","bus":0,"addr":16,"write":"02","read":"aabb","size":2}
+{"type":"uart","cmd":"AT","res":{"result":"ERROR"}}
+`
+
+	tests := []struct {
+		name string
+		fn   func(p *Replayer) error
+		want string
+	}{
+		{"addr", func(p *Replayer) (err error) { _, err = p.I2C(0).Read(0x11, 0x02, 2); return }, "mismatch"},
+		{"reg", func(p *Replayer) (err error) { _, err = p.I2C(0).Read(0x10, 0x03, 2); return }, "mismatch"},
+		{"size", func(p *Replayer) (err error) { _, err = p.I2C(0).Read(0x10, 0x02, 1); return }, "mismatch"},
+		{"bus", func(p *Replayer) (err error) { _, err = p.I2C(1).Read(0x10, 0x02, 2); return }, "mismatch"},
+		{"type", func(p *Replayer) (err error) { return p.I2C(0).Write(0x10, 0x02, []byte{0xaa, 0xbb}) }, "mismatch"},
+		{"uart", func(p *Replayer) (err error) {
+			_, err = p.Command(context.Background(), "", 0, "AT", time.Second)
+			return
+		}, "mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewReplayer(strings.NewReader(trace))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = tt.fn(p); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected %s error, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestTraceCommandError(t *testing.T) {
+	trace := `{"type":"uart","cmd":"AT","res":{"result":"ERROR"}}`
+
+	p, err := NewReplayer(strings.NewReader(trace))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.Command(context.Background(), "", 0, "AT", time.Second)
+
+	var cmdErr *CommandError
+
+	if !errors.As(err, &cmdErr) {
+		t.Errorf("expected command error, got %v", err)
+	}
+}
+
+func TestTraceErrorClass(t *testing.T) {
+	for _, c := range traceClasses {
+		err := Classify(fmt.Errorf("test"), c.class)
+
+		if name := TraceErrorClass(err); name != c.name {
+			t.Errorf("unexpected class %q for %v, want %q", name, c.class, c.name)
+		}
+
+		ev := &TraceEvent{}
+		ev.setError(err)
+
+		if !errors.Is(ev.err(), c.class) {
+			t.Errorf("class %s not restored", c.name)
+		}
+	}
+
+	if name := TraceErrorClass(errors.New("test")); name != "" {
+		t.Errorf("unexpected class %q for unclassified error", name)
+	}
+}
@@ -6,84 +6,106 @@
 // Use of this source code is governed by the license
 // that can be found in the LICENSE file.
 
-// +build linux
+// +build linux tamago
 
 package armoryctl
 
 import (
-	"errors"
-	"fmt"
-	"log"
-	"os"
-	"strings"
+	"context"
+	"log/slog"
+	"sync"
 	"time"
+)
+
+// UART represents a transport for serial AT command exchanges.
+type UART interface {
+	// Command sends an AT command to the serial device at path and
+	// returns its response (see ATPort.Command), the exchange is aborted
+	// once ctx is done.
+	Command(ctx context.Context, path string, speed int, cmd string, timeout time.Duration) (res *ATResponse, err error)
+}
 
-	"github.com/albenik/go-serial/v2"
+var (
+	uartMutex   sync.Mutex
+	uartBackend UART
 )
 
-func checkUART(path string) (err error) {
-	if _, err = os.Stat(path); os.IsNotExist(err) {
-		err = fmt.Errorf("%s missing", path)
-	}
+// SetUARTBackend replaces the system serial transport with the argument one
+// (e.g. a trace replay), a nil backend restores the system transport.
+func SetUARTBackend(backend UART) {
+	uartMutex.Lock()
+	defer uartMutex.Unlock()
 
-	return
+	uartBackend = backend
 }
 
-func UARTWrite(path string, speed int, cmd string) (res string, err error) {
-	err = checkUART(path)
+// HasUARTBackend returns whether the system serial transport is replaced by
+// a backend (see SetUARTBackend).
+func HasUARTBackend() bool {
+	uartMutex.Lock()
+	defer uartMutex.Unlock()
 
-	if err != nil {
-		return
-	}
+	return uartBackend != nil
+}
 
-	port, err := serial.Open(path, serial.WithBaudrate(speed))
+// UARTCommand sends an AT command to the serial device at path and returns
+// its response (see ATPort.Command).
+func UARTCommand(path string, speed int, cmd string, timeout time.Duration) (res *ATResponse, err error) {
+	return UARTCommandContext(context.Background(), path, speed, cmd, timeout)
+}
 
-	if err != nil {
-		return
+// UARTCommandContext is like UARTCommand but the exchange is aborted once ctx
+// is done.
+func UARTCommandContext(ctx context.Context, path string, speed int, cmd string, timeout time.Duration) (res *ATResponse, err error) {
+	uartMutex.Lock()
+	backend := uartBackend
+	uartMutex.Unlock()
+
+	start := time.Now()
+
+	if backend != nil {
+		res, err = backend.Command(ctx, path, speed, cmd, timeout)
+	} else {
+		res, err = uartCommand(ctx, path, speed, cmd, timeout)
 	}
-	defer func() { _ = port.Close() }() // make errcheck happy
 
-	if Logger != nil {
-		log.Printf(">> %s\n", cmd)
+	if rec := Recording; rec != nil {
+		rec.UART(path, speed, cmd, res, err, start)
 	}
 
-	_, err = port.Write([]byte(cmd))
+	return
+}
+
+func uartCommand(ctx context.Context, path string, speed int, cmd string, timeout time.Duration) (res *ATResponse, err error) {
+	port, err := OpenUART(path, speed)
 
 	if err != nil {
 		return
 	}
+	defer func() { _ = port.Close() }() // make errcheck happy
 
-	time.Sleep(500 * time.Millisecond)
+	// closing the port unblocks any pending read on cancellation
+	stop := context.AfterFunc(ctx, func() { _ = port.Close() })
+	defer stop()
 
-	n := 0
-	r := make([]byte, 1024)
+	start := time.Now()
+	res, err = NewATPort(port).Command(ctx, cmd, timeout)
 
-	for {
-		n, err = port.Read(r)
+	attrs := []any{LogBus, path, "command", cmd, LogDuration, time.Since(start)}
 
-		if err != nil {
-			return
-		}
+	if res != nil {
+		attrs = append(attrs, "result", res.Result)
 
-		if strings.Contains(string(r), "OK") {
-			break
-		}
-
-		if strings.Contains(string(r), "ERROR") {
-			err = errors.New("response error")
-			return
-		}
-
-		if n == 0 {
-			break
+		if LogEnabled(LevelTrace) {
+			attrs = append(attrs, "lines", res.Lines)
 		}
 	}
 
-	res = string(r[len(cmd):])
-
-	if Logger != nil {
-		log.Printf("<< %s\n", res)
+	if err != nil {
+		attrs = append(attrs, "error", err)
 	}
 
+	Log(slog.LevelDebug, "AT command", attrs...)
+
 	return
 }
@@ -0,0 +1,83 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build linux
+
+package armoryctl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/albenik/go-serial/v2"
+)
+
+// serial read timeout, for the first received byte, in milliseconds
+const uartReadTimeout = 100
+
+func checkUART(path string) (err error) {
+	if _, err = os.Stat(path); os.IsNotExist(err) {
+		err = Classify(fmt.Errorf("%s missing", path), ErrBusNotFound)
+	}
+
+	return
+}
+
+// uartPort represents an open serial device, holding its bus lock until
+// closed.
+type uartPort struct {
+	*serial.Port
+
+	lock *Lock
+	once sync.Once
+}
+
+func (p *uartPort) Close() (err error) {
+	err = p.Port.Close()
+	p.once.Do(func() { _ = p.lock.Unlock() })
+
+	return
+}
+
+// OpenUART opens the serial device at path, reads return with no data when
+// none is received within a short timeout (see ATPort).
+//
+// The device is locked against concurrent use by other processes until the
+// port is closed, ErrBusy is returned if the lock is not acquired within
+// LockTimeout.
+func OpenUART(path string, speed int) (port io.ReadWriteCloser, err error) {
+	err = checkUART(path)
+
+	if err != nil {
+		return
+	}
+
+	lock, err := LockPath(path, LockTimeout)
+
+	if err != nil {
+		return
+	}
+
+	p, err := serial.Open(path, serial.WithBaudrate(speed))
+
+	if err != nil {
+		_ = lock.Unlock()
+		return
+	}
+
+	// return from reads as soon as any data is received
+	if err = p.SetFirstByteReadTimeout(uartReadTimeout); err != nil {
+		_ = p.Close()
+		_ = lock.Unlock()
+		return
+	}
+
+	return &uartPort{Port: p, lock: lock}, nil
+}
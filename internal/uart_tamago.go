@@ -0,0 +1,55 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// +build tamago,arm
+
+package armoryctl
+
+import (
+	"fmt"
+	"io"
+
+	usbarmory "github.com/usbarmory/tamago/board/usbarmory/mk2"
+)
+
+// uartPort represents the BLE module UART, reads are non-blocking and return
+// with no data when none is available (see ATPort).
+type uartPort struct{}
+
+func (p uartPort) Read(buf []byte) (n int, err error) {
+	return usbarmory.BLE.UART.Read(buf)
+}
+
+func (p uartPort) Write(buf []byte) (n int, err error) {
+	return usbarmory.BLE.UART.Write(buf)
+}
+
+// Close has no effect as the controller is initialized once and never
+// released.
+func (p uartPort) Close() (err error) {
+	return
+}
+
+// OpenUART opens the BLE module UART, the path is ignored as no other serial
+// port is available. The speed is applied on first use only, as the
+// controller is initialized once.
+func OpenUART(path string, speed int) (port io.ReadWriteCloser, err error) {
+	if usbarmory.UART1.Baudrate == 0 {
+		usbarmory.UART1.Baudrate = uint32(speed)
+	}
+
+	if err = InitBLE(); err != nil {
+		return
+	}
+
+	if usbarmory.UART1.Baudrate != uint32(speed) {
+		return nil, fmt.Errorf("UART speed must be set to %d", usbarmory.UART1.Baudrate)
+	}
+
+	return uartPort{}, nil
+}
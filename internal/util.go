@@ -11,124 +11,202 @@
 package armoryctl
 
 import (
-	"archive/zip"
 	"bytes"
-	"errors"
+	"context"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
-	"path"
-	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
-var Logger *log.Logger
+// Privilege escalation methods for commands requiring root privileges.
+const (
+	EscalateSudo   = "sudo"
+	EscalateDoas   = "doas"
+	EscalatePkexec = "pkexec"
+	// EscalateNone runs commands as the current user, for instance when
+	// the required capabilities are already granted.
+	EscalateNone = "none"
+)
+
+// Escalation is the privilege escalation method for commands requiring root
+// privileges, escalation is skipped when already running as root.
+var Escalation = EscalateSudo
+
+// ExecError is returned when an executed command fails.
+type ExecError struct {
+	// Command name
+	Command string
+	// Command arguments
+	Args []string
+	// Exit status, -1 if the command did not exit normally
+	ExitCode int
+	// Standard output
+	Stdout string
+	// Standard error
+	Stderr string
+	// Underlying error
+	Err error
+}
 
-const traversalPattern = `../`
+func (e *ExecError) Error() string {
+	msg := strings.TrimSpace(e.Stderr)
 
-func UnzipFile(src string, dst string) (err error) {
-	if Logger != nil {
-		log.Printf("uncompressing %s in %s\n", src, dst)
+	if msg == "" {
+		return fmt.Sprintf("%s failed, %v", e.Command, e.Err)
 	}
 
-	reader, err := zip.OpenReader(src)
+	return fmt.Sprintf("%s failed, %v: %s", e.Command, e.Err, msg)
+}
 
-	if err != nil {
-		return
-	}
-	defer func() { _ = reader.Close() }() // make errcheck happy
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
 
-	err = os.MkdirAll(dst, 0700)
+// lineWriter collects command output while passing each line to a callback.
+type lineWriter struct {
+	mu *sync.Mutex
 
-	if err != nil {
-		return
-	}
+	buf  bytes.Buffer
+	line []byte
+	f    func(string)
+}
 
-	for _, f := range reader.Reader.File {
-		if strings.Contains(f.Name, traversalPattern) {
-			return fmt.Errorf("path traversal detected")
-		}
+func (w *lineWriter) emit(line []byte) {
+	if s := strings.TrimSpace(string(line)); s != "" {
+		w.f(s)
+	}
+}
 
-		dstPath := filepath.Join(dst, f.Name)
+func (w *lineWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-		if f.FileInfo().IsDir() {
-			err = os.MkdirAll(dstPath, f.Mode())
+	w.buf.Write(p)
+	w.line = append(w.line, p...)
 
-			if err != nil {
-				return
-			}
-		} else {
-			err = os.MkdirAll(path.Dir(dstPath), 0700)
+	for {
+		i := bytes.IndexAny(w.line, "\r\n")
 
-			if err != nil {
-				return
-			}
+		if i < 0 {
+			break
+		}
 
-			output, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_TRUNC, f.Mode())
+		w.emit(w.line[:i])
+		w.line = w.line[i+1:]
+	}
 
-			if err != nil {
-				return err
-			}
-			defer func() { _ = output.Close() }() // make errcheck happy
+	return len(p), nil
+}
 
-			input, err := f.Open()
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-			if err != nil {
-				return err
-			}
-			defer func() { _ = input.Close() }() // make errcheck happy
+	w.emit(w.line)
+	w.line = nil
+}
 
-			_, err = io.Copy(output, input)
+func escalate(cmd string, args []string) (string, []string, error) {
+	if Escalation == EscalateNone || os.Geteuid() == 0 {
+		return cmd, args, nil
+	}
 
-			if err != nil {
-				return err
-			}
+	switch Escalation {
+	case EscalateSudo, EscalateDoas, EscalatePkexec:
+		path, err := exec.LookPath(Escalation)
 
-			_ = output.Close()
-			_ = os.Chtimes(dstPath, f.ModTime(), f.ModTime())
+		if err != nil {
+			return "", nil, err
 		}
-	}
 
-	return
+		return path, append([]string{cmd}, args...), nil
+	default:
+		return "", nil, fmt.Errorf("invalid privilege escalation method %s", Escalation)
+	}
 }
 
+// ExecCommand executes a command, with root privileges (see Escalation) if
+// root is true, feeding input to its standard input and returning its
+// standard output. A failed command returns an *ExecError.
 func ExecCommand(cmd string, args []string, root bool, input string) (output string, err error) {
-	var c *exec.Cmd
+	return ExecCommandContext(context.Background(), cmd, args, root, input)
+}
+
+// ExecCommandContext is like ExecCommand but the command is killed once ctx
+// is done.
+func ExecCommandContext(ctx context.Context, cmd string, args []string, root bool, input string) (output string, err error) {
+	return ExecCommandStream(ctx, cmd, args, root, input, nil)
+}
+
+// ExecCommandStream is like ExecCommandContext but each standard output and
+// error line is passed to f, when not nil, as soon as it is received.
+func ExecCommandStream(ctx context.Context, cmd string, args []string, root bool, input string, f func(line string)) (output string, err error) {
+	name := cmd
+	argv := args
 
 	if root {
-		c = exec.Command("/usr/bin/sudo", append([]string{cmd}, args...)...)
-	} else {
-		c = exec.Command(cmd, args...)
+		if cmd, args, err = escalate(cmd, args); err != nil {
+			return
+		}
 	}
 
-	if Logger != nil {
-		log.Printf("executing: %s %s\n", cmd, args)
+	c := exec.CommandContext(ctx, cmd, args...)
+
+	// SIGTERM, unlike the default SIGKILL, is relayed by sudo to the
+	// command, which is killed only if it does not exit in time.
+	c.Cancel = func() error {
+		return c.Process.Signal(syscall.SIGTERM)
 	}
+	c.WaitDelay = 5 * time.Second
 
-	var stdin bytes.Buffer
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
+	Log(slog.LevelDebug, "executing", "command", cmd, "args", args)
 
-	if input != "" {
-		_, err = stdin.WriteString(input)
-		c.Stdin = &stdin
+	line := func(s string) {
+		Log(slog.LevelDebug, "output", "command", name, "line", s)
 
-		if err != nil {
-			err = fmt.Errorf("error writing to stdin")
-			return
+		if f != nil {
+			f(s)
 		}
 	}
 
-	c.Stdout = &stdout
-	c.Stderr = &stderr
+	// the streams are copied concurrently, a shared lock serializes f
+	mu := &sync.Mutex{}
+	stdout := &lineWriter{mu: mu, f: line}
+	stderr := &lineWriter{mu: mu, f: line}
+
+	if input != "" {
+		c.Stdin = strings.NewReader(input)
+	}
+
+	c.Stdout = stdout
+	c.Stderr = stderr
 
 	err = c.Run()
 
+	stdout.flush()
+	stderr.flush()
+
+	output = stdout.buf.String()
+
+	if ctx.Err() != nil {
+		return output, ctx.Err()
+	}
+
 	if err != nil {
-		err = errors.New(stderr.String())
+		err = &ExecError{
+			Command:  name,
+			Args:     argv,
+			ExitCode: c.ProcessState.ExitCode(),
+			Stdout:   output,
+			Stderr:   stderr.buf.String(),
+			Err:      err,
+		}
 	}
 
-	return stdout.String(), err
+	return
 }
@@ -0,0 +1,424 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package armoryctl
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Archive extraction limits, enforced on both declared and actual entry
+// sizes.
+var (
+	// UnzipMaxFiles is the maximum number of archive entries.
+	UnzipMaxFiles = 1024
+	// UnzipMaxFileSize is the maximum uncompressed size of a single file.
+	UnzipMaxFileSize int64 = 64 << 20
+	// UnzipMaxSize is the maximum uncompressed size of all files.
+	UnzipMaxSize int64 = 256 << 20
+)
+
+// ErrUnsafeArchive is returned when an archive is refused for extraction.
+var ErrUnsafeArchive = errors.New("unsafe archive")
+
+func unsafeArchive(format string, args ...any) error {
+	return fmt.Errorf("%w, %s", ErrUnsafeArchive, fmt.Sprintf(format, args...))
+}
+
+// zipEntry represents a validated archive entry.
+type zipEntry struct {
+	*zip.File
+
+	// cleaned slash separated path, relative to the archive root
+	name string
+}
+
+// checkZip validates all archive entries before any extraction takes place,
+// only regular files and directories with unique names contained within the
+// archive root are accepted.
+func checkZip(r *zip.Reader) (entries []zipEntry, err error) {
+	var total int64
+
+	if len(r.File) > UnzipMaxFiles {
+		return nil, unsafeArchive("%d entries exceed limit", len(r.File))
+	}
+
+	names := make(map[string]bool)
+
+	for _, f := range r.File {
+		name := f.Name
+
+		if strings.Contains(name, `\`) || filepath.VolumeName(name) != "" {
+			return nil, unsafeArchive("invalid path %q", f.Name)
+		}
+
+		name = strings.TrimSuffix(name, "/")
+
+		if !fs.ValidPath(name) || name == "." {
+			return nil, unsafeArchive("invalid path %q", f.Name)
+		}
+
+		switch mode := f.Mode(); {
+		case mode.IsDir():
+		case mode.IsRegular():
+			size := int64(f.UncompressedSize64)
+
+			if f.UncompressedSize64 > uint64(UnzipMaxFileSize) {
+				return nil, unsafeArchive("%s size exceeds limit", name)
+			}
+
+			if total += size; total > UnzipMaxSize {
+				return nil, unsafeArchive("total size exceeds limit")
+			}
+		default:
+			return nil, unsafeArchive("%s is not a regular file (%v)", name, mode.Type())
+		}
+
+		if names[name] {
+			return nil, unsafeArchive("duplicate entry %s", name)
+		}
+
+		names[name] = true
+		entries = append(entries, zipEntry{f, name})
+	}
+
+	return
+}
+
+// readZipEntry copies an archive file entry to w, enforcing size limits
+// regardless of its declared size.
+func readZipEntry(w io.Writer, f *zip.File, total *int64) (err error) {
+	input, err := f.Open()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = input.Close() }() // make errcheck happy
+
+	max := min(UnzipMaxFileSize, UnzipMaxSize-*total)
+	n, err := io.Copy(w, io.LimitReader(input, max+1))
+
+	if err != nil {
+		return
+	}
+
+	if n > max {
+		return unsafeArchive("%s size exceeds limit", f.Name)
+	}
+
+	*total += n
+
+	return
+}
+
+func unzipEntry(e zipEntry, dstPath string, total *int64) (err error) {
+	output, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, e.Mode().Perm())
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = output.Close() }() // make errcheck happy
+
+	if err = readZipEntry(output, e.File, total); err != nil {
+		return
+	}
+
+	if err = output.Close(); err != nil {
+		return
+	}
+
+	_ = os.Chtimes(dstPath, e.Modified, e.Modified)
+
+	return
+}
+
+// Unzip extracts the archive to the dst directory, which is created if
+// missing. Entries with unsafe paths or types, or exceeding the extraction
+// limits, result in ErrUnsafeArchive, existing files are never overwritten.
+func Unzip(r *zip.Reader, dst string) (err error) {
+	var total int64
+
+	entries, err := checkZip(r)
+
+	if err != nil {
+		return
+	}
+
+	if dst, err = filepath.Abs(dst); err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(dst, 0700); err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		dstPath := filepath.Join(dst, filepath.FromSlash(e.name))
+
+		if rel, err := filepath.Rel(dst, dstPath); err != nil || !filepath.IsLocal(rel) {
+			return unsafeArchive("invalid path %q", e.Name)
+		}
+
+		if e.Mode().IsDir() {
+			err = os.MkdirAll(dstPath, 0700)
+		} else if err = os.MkdirAll(filepath.Dir(dstPath), 0700); err == nil {
+			err = unzipEntry(e, dstPath, &total)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// UnzipFile extracts the src archive to the dst directory (see Unzip).
+func UnzipFile(src string, dst string) (err error) {
+	Log(slog.LevelDebug, "uncompressing", "src", src, "dst", dst)
+
+	reader, err := zip.OpenReader(src)
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = reader.Close() }() // make errcheck happy
+
+	return Unzip(&reader.Reader, dst)
+}
+
+// UnzipFS extracts the archive in memory, with the same checks and limits of
+// Unzip, and returns its contents as a read-only file system.
+func UnzipFS(r *zip.Reader) (fsys fs.FS, err error) {
+	var total int64
+
+	entries, err := checkZip(r)
+
+	if err != nil {
+		return
+	}
+
+	m := memFS{".": {name: ".", mode: fs.ModeDir | 0555}}
+
+	for _, e := range entries {
+		var buf bytes.Buffer
+
+		if e.Mode().IsDir() {
+			if _, err = m.mkdir(e.name, e.Modified); err != nil {
+				return
+			}
+
+			continue
+		}
+
+		if err = readZipEntry(&buf, e.File, &total); err != nil {
+			return
+		}
+
+		f := &memFile{
+			name:    path.Base(e.name),
+			data:    buf.Bytes(),
+			mode:    e.Mode().Perm(),
+			modTime: e.Modified,
+		}
+
+		if err = m.add(e.name, f); err != nil {
+			return
+		}
+	}
+
+	for _, f := range m {
+		slices.SortFunc(f.entries, func(a, b fs.DirEntry) int {
+			return strings.Compare(a.Name(), b.Name())
+		})
+	}
+
+	return m, nil
+}
+
+// UnzipFileFS extracts the src archive in memory (see UnzipFS).
+func UnzipFileFS(src string) (fsys fs.FS, err error) {
+	Log(slog.LevelDebug, "uncompressing", "src", src)
+
+	reader, err := zip.OpenReader(src)
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = reader.Close() }() // make errcheck happy
+
+	return UnzipFS(&reader.Reader)
+}
+
+// memFile represents an in-memory file or directory, it implements both
+// fs.FileInfo and fs.DirEntry.
+type memFile struct {
+	name    string
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+	entries []fs.DirEntry
+}
+
+func (f *memFile) Name() string               { return f.name }
+func (f *memFile) Size() int64                { return int64(len(f.data)) }
+func (f *memFile) Mode() fs.FileMode          { return f.mode }
+func (f *memFile) ModTime() time.Time         { return f.modTime }
+func (f *memFile) IsDir() bool                { return f.mode.IsDir() }
+func (f *memFile) Sys() any                   { return nil }
+func (f *memFile) Type() fs.FileMode          { return f.mode.Type() }
+func (f *memFile) Info() (fs.FileInfo, error) { return f, nil }
+
+// openFile represents an open memFile.
+type openFile struct {
+	*memFile
+
+	r   *bytes.Reader
+	off int
+}
+
+func (o *openFile) Stat() (fs.FileInfo, error) {
+	return o.memFile, nil
+}
+
+func (o *openFile) Read(p []byte) (int, error) {
+	if o.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: o.name, Err: errors.New("is a directory")}
+	}
+
+	return o.r.Read(p)
+}
+
+func (o *openFile) ReadDir(n int) (entries []fs.DirEntry, err error) {
+	if !o.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: o.name, Err: errors.New("not a directory")}
+	}
+
+	entries = o.entries[o.off:]
+
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+
+	o.off += len(entries)
+
+	return
+}
+
+func (o *openFile) Close() error {
+	return nil
+}
+
+// memFS represents an in-memory read-only file system, indexed by path.
+type memFS map[string]*memFile
+
+func (m memFS) mkdir(name string, modTime time.Time) (d *memFile, err error) {
+	if d = m[name]; d != nil {
+		if !d.IsDir() {
+			return nil, unsafeArchive("%s is both a file and a directory", name)
+		}
+
+		return
+	}
+
+	d = &memFile{
+		name:    path.Base(name),
+		mode:    fs.ModeDir | 0555,
+		modTime: modTime,
+	}
+
+	return d, m.add(name, d)
+}
+
+func (m memFS) add(name string, f *memFile) (err error) {
+	if _, ok := m[name]; ok {
+		return unsafeArchive("%s is both a file and a directory", name)
+	}
+
+	parent, err := m.mkdir(path.Dir(name), f.modTime)
+
+	if err != nil {
+		return
+	}
+
+	m[name] = f
+	parent.entries = append(parent.entries, f)
+
+	return
+}
+
+func (m memFS) lookup(op string, name string) (*memFile, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	f, ok := m[name]
+
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return f, nil
+}
+
+// Open implements fs.FS.
+func (m memFS) Open(name string) (fs.File, error) {
+	f, err := m.lookup("open", name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &openFile{memFile: f, r: bytes.NewReader(f.data)}, nil
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (m memFS) ReadFile(name string) ([]byte, error) {
+	f, err := m.lookup("read", name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if f.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+
+	return slices.Clone(f.data), nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (m memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := m.lookup("readdir", name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !f.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	return slices.Clone(f.entries), nil
+}
@@ -0,0 +1,222 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package armoryctl
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// testEntry represents an archive entry, a nil data results in a directory.
+type testEntry struct {
+	name string
+	data []byte
+	mode fs.FileMode
+}
+
+func testZip(t *testing.T, entries ...testEntry) *zip.Reader {
+	var buf bytes.Buffer
+
+	w := zip.NewWriter(&buf)
+
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Store}
+
+		switch {
+		case e.mode != 0:
+			h.SetMode(e.mode)
+		case e.data == nil:
+			h.SetMode(fs.ModeDir | 0755)
+		default:
+			h.SetMode(0644)
+		}
+
+		f, err := w.CreateHeader(h)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = f.Write(e.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func setUnzipLimits(t *testing.T, files int, fileSize int64, size int64) {
+	maxFiles, maxFileSize, maxSize := UnzipMaxFiles, UnzipMaxFileSize, UnzipMaxSize
+	UnzipMaxFiles, UnzipMaxFileSize, UnzipMaxSize = files, fileSize, size
+
+	t.Cleanup(func() {
+		UnzipMaxFiles, UnzipMaxFileSize, UnzipMaxSize = maxFiles, maxFileSize, maxSize
+	})
+}
+
+func TestCheckZip(t *testing.T) {
+	data := []byte("data")
+
+	tests := []struct {
+		name    string
+		entries []testEntry
+		unsafe  bool
+	}{
+		{"valid", []testEntry{{name: "dir/"}, {name: "dir/a.bin", data: data}, {name: "b.bin", data: data}}, false},
+		{"parent", []testEntry{{name: "../a.bin", data: data}}, true},
+		{"nested parent", []testEntry{{name: "dir/../../a.bin", data: data}}, true},
+		{"absolute", []testEntry{{name: "/etc/a.bin", data: data}}, true},
+		{"backslash", []testEntry{{name: `..\a.bin`, data: data}}, true},
+		{"root", []testEntry{{name: "./"}}, true},
+		{"empty", []testEntry{{name: "", data: data}}, true},
+		{"duplicate", []testEntry{{name: "a.bin", data: data}, {name: "a.bin", data: data}}, true},
+		{"duplicate dir", []testEntry{{name: "dir/"}, {name: "dir", data: data}}, true},
+		{"symlink", []testEntry{{name: "a.bin", data: []byte("/etc/passwd"), mode: fs.ModeSymlink | 0777}}, true},
+		{"file limit", []testEntry{{name: "a/"}, {name: "b/"}, {name: "c/"}, {name: "d/"}}, true},
+		{"file size", []testEntry{{name: "a.bin", data: make([]byte, 9)}}, true},
+		{"total size", []testEntry{{name: "a.bin", data: make([]byte, 8)}, {name: "b.bin", data: make([]byte, 8)}}, true},
+	}
+
+	setUnzipLimits(t, 3, 8, 12)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := checkZip(testZip(t, tt.entries...))
+
+			if tt.unsafe && !errors.Is(err, ErrUnsafeArchive) {
+				t.Errorf("expected unsafe archive, got %v", err)
+			}
+
+			if !tt.unsafe && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestUnzip(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "out")
+	r := testZip(t,
+		testEntry{name: "dir/"},
+		testEntry{name: "dir/a.bin", data: []byte("a")},
+		testEntry{name: "sub/b.bin", data: []byte("b")},
+	)
+
+	if err := Unzip(r, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{"dir/a.bin": "a", "sub/b.bin": "b"} {
+		if buf, err := os.ReadFile(filepath.Join(dst, name)); err != nil || string(buf) != want {
+			t.Errorf("unexpected %s content %q (%v)", name, buf, err)
+		}
+	}
+
+	// existing files are never overwritten
+	if err := Unzip(r, dst); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected existing file error, got %v", err)
+	}
+
+	// unsafe archives are refused before any extraction
+	dst = filepath.Join(t.TempDir(), "out")
+	r = testZip(t,
+		testEntry{name: "a.bin", data: []byte("a")},
+		testEntry{name: "../b.bin", data: []byte("b")},
+	)
+
+	if err := Unzip(r, dst); !errors.Is(err, ErrUnsafeArchive) {
+		t.Errorf("expected unsafe archive, got %v", err)
+	}
+
+	if _, err := os.Stat(dst); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected extraction of unsafe archive (%v)", err)
+	}
+}
+
+func TestUnzipSizeLimit(t *testing.T) {
+	var buf bytes.Buffer
+
+	// an entry declaring a size lower than its actual one
+	w := zip.NewWriter(&buf)
+	h := &zip.FileHeader{Name: "a.bin", Method: zip.Store, UncompressedSize64: 4, CompressedSize64: 32}
+	h.SetMode(0644)
+
+	f, err := w.CreateRaw(h)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = f.Write(make([]byte, 32)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setUnzipLimits(t, 1, 8, 8)
+
+	if _, err = UnzipFS(r); err == nil {
+		t.Error("expected size error")
+	}
+
+	if err = Unzip(r, t.TempDir()); err == nil {
+		t.Error("expected size error")
+	}
+}
+
+func TestUnzipFS(t *testing.T) {
+	r := testZip(t,
+		testEntry{name: "dir/"},
+		testEntry{name: "dir/b.bin", data: []byte("b")},
+		testEntry{name: "dir/a.bin", data: []byte("a")},
+		testEntry{name: "sub/c.bin", data: []byte("c")},
+	)
+
+	fsys, err := UnzipFS(r)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = fstest.TestFS(fsys, "dir/a.bin", "dir/b.bin", "sub/c.bin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf, err := fs.ReadFile(fsys, "sub/c.bin"); err != nil || string(buf) != "c" {
+		t.Errorf("unexpected content %q (%v)", buf, err)
+	}
+
+	if _, err := UnzipFS(testZip(t, testEntry{name: "a/b", data: []byte("b")}, testEntry{name: "a", data: []byte("a")})); !errors.Is(err, ErrUnsafeArchive) {
+		t.Errorf("expected unsafe archive, got %v", err)
+	}
+}
@@ -19,11 +19,17 @@ import (
 	"github.com/usbarmory/armoryctl/internal"
 )
 
+// Default instance bus number and address, used by the package level
+// functions.
 var (
 	I2CBus     = 0
 	I2CAddress = 0x08
 )
 
+// Retry sets the I²C transfer retry policy, the global one is used when nil
+// (see armoryctl.Retry).
+var Retry *armoryctl.RetryPolicy
+
 // DEVICE_ID (p53, Table 52, PF1510 Datasheet).
 var DeviceID = map[byte]string{
 	0x4: "PF1510",
@@ -34,31 +40,433 @@ var Family = map[byte]string{
 	15: "15",
 }
 
+// Register map (PF1510 Datasheet, Register map).
+var Registers = armoryctl.RegisterMap{
+	{
+		Name:    "DEVICE_ID",
+		Address: 0x00,
+		Access:  armoryctl.ReadOnly,
+		Fields: []*armoryctl.Field{
+			{Name: "FAMILY", Shift: 3, Width: 5, Values: Family},
+			{Name: "DEVICE_ID", Shift: 0, Width: 3, Values: DeviceID},
+		},
+	},
+	{
+		Name:    "OTP_FLAVOR",
+		Address: 0x01,
+		Access:  armoryctl.ReadOnly,
+	},
+	{
+		Name:    "SILICON_REV",
+		Address: 0x02,
+		Access:  armoryctl.ReadOnly,
+	},
+	// sense registers report the current interrupt sources state
+	{
+		Name:    "INT_CATEGORY",
+		Address: 0x06,
+		Access:  armoryctl.ReadOnly,
+	},
+	{
+		Name:    "SW_INT_STAT0",
+		Address: 0x08,
+		Access:  armoryctl.WriteOneToClear,
+	},
+	{
+		Name:    "SW_INT_MASK0",
+		Address: 0x09,
+	},
+	{
+		Name:    "SW_INT_SENSE0",
+		Address: 0x0a,
+		Access:  armoryctl.ReadOnly,
+	},
+	{
+		Name:    "SW_INT_STAT1",
+		Address: 0x0b,
+		Access:  armoryctl.WriteOneToClear,
+	},
+	{
+		Name:    "SW_INT_MASK1",
+		Address: 0x0c,
+	},
+	{
+		Name:    "SW_INT_SENSE1",
+		Address: 0x0d,
+		Access:  armoryctl.ReadOnly,
+	},
+	{
+		Name:    "SW_INT_STAT2",
+		Address: 0x0e,
+		Access:  armoryctl.WriteOneToClear,
+	},
+	{
+		Name:    "SW_INT_MASK2",
+		Address: 0x0f,
+	},
+	{
+		Name:    "SW_INT_SENSE2",
+		Address: 0x10,
+		Access:  armoryctl.ReadOnly,
+	},
+	{
+		Name:    "LDO_INT_STAT0",
+		Address: 0x18,
+		Access:  armoryctl.WriteOneToClear,
+	},
+	{
+		Name:    "LDO_INT_MASK0",
+		Address: 0x19,
+	},
+	{
+		Name:    "LDO_INT_SENSE0",
+		Address: 0x1a,
+		Access:  armoryctl.ReadOnly,
+	},
+	{
+		Name:    "TEMP_INT_STAT0",
+		Address: 0x20,
+		Access:  armoryctl.WriteOneToClear,
+	},
+	{
+		Name:    "TEMP_INT_MASK0",
+		Address: 0x21,
+	},
+	{
+		Name:    "TEMP_INT_SENSE0",
+		Address: 0x22,
+		Access:  armoryctl.ReadOnly,
+	},
+	{
+		Name:    "ONKEY_INT_STAT0",
+		Address: 0x24,
+		Access:  armoryctl.WriteOneToClear,
+	},
+	{
+		Name:    "ONKEY_INT_MASK0",
+		Address: 0x25,
+	},
+	{
+		Name:    "ONKEY_INT_SENSE0",
+		Address: 0x26,
+		Access:  armoryctl.ReadOnly,
+	},
+	{
+		Name:    "MISC_INT_STAT0",
+		Address: 0x28,
+		Access:  armoryctl.WriteOneToClear,
+	},
+	{
+		Name:    "MISC_INT_MASK0",
+		Address: 0x29,
+	},
+	{
+		Name:    "MISC_INT_SENSE0",
+		Address: 0x2a,
+		Access:  armoryctl.ReadOnly,
+	},
+	{
+		Name:    "COINCELL_CONTROL",
+		Address: 0x30,
+		Fields: []*armoryctl.Field{
+			{Name: "COINCHEN", Shift: 4, Width: 1},
+			{Name: "VCOIN", Shift: 0, Width: 4},
+		},
+	},
+	{
+		Name:    "SW1_VOLT",
+		Address: 0x32,
+		Fields: []*armoryctl.Field{
+			{Name: "VOLT", Shift: 0, Width: 6},
+		},
+	},
+	{
+		Name:    "SW1_STBY_VOLT",
+		Address: 0x33,
+		Fields: []*armoryctl.Field{
+			{Name: "VOLT", Shift: 0, Width: 6},
+		},
+	},
+	{
+		Name:    "SW1_SLP_VOLT",
+		Address: 0x34,
+		Fields: []*armoryctl.Field{
+			{Name: "VOLT", Shift: 0, Width: 6},
+		},
+	},
+	{
+		Name:    "SW1_CTRL",
+		Address: 0x35,
+		Fields: []*armoryctl.Field{
+			{Name: "LPWR", Shift: 3, Width: 1},
+			{Name: "OMODE", Shift: 2, Width: 1},
+			{Name: "STBY_EN", Shift: 1, Width: 1},
+			{Name: "EN", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "SW1_CTRL1",
+		Address: 0x36,
+	},
+	{
+		Name:    "SW2_VOLT",
+		Address: 0x38,
+		Fields: []*armoryctl.Field{
+			{Name: "VOLT", Shift: 0, Width: 6},
+		},
+	},
+	{
+		Name:    "SW2_STBY_VOLT",
+		Address: 0x39,
+		Fields: []*armoryctl.Field{
+			{Name: "VOLT", Shift: 0, Width: 6},
+		},
+	},
+	{
+		Name:    "SW2_SLP_VOLT",
+		Address: 0x3a,
+		Fields: []*armoryctl.Field{
+			{Name: "VOLT", Shift: 0, Width: 6},
+		},
+	},
+	{
+		Name:    "SW2_CTRL",
+		Address: 0x3b,
+		Fields: []*armoryctl.Field{
+			{Name: "LPWR", Shift: 3, Width: 1},
+			{Name: "OMODE", Shift: 2, Width: 1},
+			{Name: "STBY_EN", Shift: 1, Width: 1},
+			{Name: "EN", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "SW2_CTRL1",
+		Address: 0x3c,
+	},
+	{
+		Name:    "SW3_VOLT",
+		Address: 0x3e,
+		Fields: []*armoryctl.Field{
+			{Name: "VOLT", Shift: 0, Width: 6},
+		},
+	},
+	{
+		Name:    "SW3_STBY_VOLT",
+		Address: 0x3f,
+		Fields: []*armoryctl.Field{
+			{Name: "VOLT", Shift: 0, Width: 6},
+		},
+	},
+	{
+		Name:    "SW3_SLP_VOLT",
+		Address: 0x40,
+		Fields: []*armoryctl.Field{
+			{Name: "VOLT", Shift: 0, Width: 6},
+		},
+	},
+	{
+		Name:    "SW3_CTRL",
+		Address: 0x41,
+		Fields: []*armoryctl.Field{
+			{Name: "LPWR", Shift: 3, Width: 1},
+			{Name: "OMODE", Shift: 2, Width: 1},
+			{Name: "STBY_EN", Shift: 1, Width: 1},
+			{Name: "EN", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "SW3_CTRL1",
+		Address: 0x42,
+	},
+	{
+		Name:    "VSNVS_CTRL",
+		Address: 0x48,
+	},
+	{
+		Name:    "VREFDDR_CTRL",
+		Address: 0x4a,
+		Fields: []*armoryctl.Field{
+			{Name: "OMODE", Shift: 2, Width: 1},
+			{Name: "STBY_EN", Shift: 1, Width: 1},
+			{Name: "EN", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "LDO1_VOLT",
+		Address: 0x4c,
+		Fields: []*armoryctl.Field{
+			{Name: "VOLT", Shift: 0, Width: 5},
+		},
+	},
+	{
+		Name:    "LDO1_CTRL",
+		Address: 0x4d,
+		Fields: []*armoryctl.Field{
+			{Name: "LPWR", Shift: 3, Width: 1},
+			{Name: "OMODE", Shift: 2, Width: 1},
+			{Name: "STBY_EN", Shift: 1, Width: 1},
+			{Name: "EN", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "LDO2_VOLT",
+		Address: 0x4f,
+		Fields: []*armoryctl.Field{
+			{Name: "VOLT", Shift: 0, Width: 5},
+		},
+	},
+	{
+		Name:    "LDO2_CTRL",
+		Address: 0x50,
+		Fields: []*armoryctl.Field{
+			{Name: "LPWR", Shift: 3, Width: 1},
+			{Name: "OMODE", Shift: 2, Width: 1},
+			{Name: "STBY_EN", Shift: 1, Width: 1},
+			{Name: "EN", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "LDO3_VOLT",
+		Address: 0x52,
+		Fields: []*armoryctl.Field{
+			{Name: "VOLT", Shift: 0, Width: 5},
+		},
+	},
+	{
+		Name:    "LDO3_CTRL",
+		Address: 0x53,
+		Fields: []*armoryctl.Field{
+			{Name: "LPWR", Shift: 3, Width: 1},
+			{Name: "OMODE", Shift: 2, Width: 1},
+			{Name: "STBY_EN", Shift: 1, Width: 1},
+			{Name: "EN", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "PWRCTRL0",
+		Address: 0x58,
+	},
+	{
+		Name:    "PWRCTRL1",
+		Address: 0x59,
+	},
+	{
+		Name:    "PWRCTRL2",
+		Address: 0x5a,
+	},
+	{
+		Name:    "PWRCTRL3",
+		Address: 0x5b,
+	},
+	{
+		Name:    "SW1_PWRDN_SEQ",
+		Address: 0x5f,
+	},
+	{
+		Name:    "SW2_PWRDN_SEQ",
+		Address: 0x60,
+	},
+	{
+		Name:    "SW3_PWRDN_SEQ",
+		Address: 0x61,
+	},
+	{
+		Name:    "LDO1_PWRDN_SEQ",
+		Address: 0x62,
+	},
+	{
+		Name:    "LDO2_PWRDN_SEQ",
+		Address: 0x63,
+	},
+	{
+		Name:    "LDO3_PWRDN_SEQ",
+		Address: 0x64,
+	},
+	{
+		Name:    "VREFDDR_PWRDN_SEQ",
+		Address: 0x65,
+	},
+	{
+		Name:    "STATE_INFO",
+		Address: 0x67,
+		Access:  armoryctl.ReadOnly,
+	},
+	{
+		Name:    "I2C_ADDR",
+		Address: 0x68,
+	},
+	{
+		Name:    "IO_DRV0",
+		Address: 0x69,
+	},
+	{
+		Name:    "IO_DRV1",
+		Address: 0x6a,
+	},
+	{
+		Name:    "RC_16MHZ",
+		Address: 0x6b,
+	},
+	// protects OTP and test registers access
+	{
+		Name:    "KEY",
+		Address: 0x6f,
+	},
+}
+
+// Device represents a PF1510 PMIC instance.
+type Device struct {
+	// I²C bus transport
+	Bus armoryctl.I2C
+	// I²C slave address
+	Address int
+}
+
+// New returns a PF1510 PMIC instance on the given bus and address.
+func New(bus armoryctl.I2C, addr int) *Device {
+	return &Device{
+		Bus:     bus,
+		Address: addr,
+	}
+}
+
+func openDefault() (d *Device, bus armoryctl.I2CHandle, err error) {
+	bus, err = armoryctl.OpenI2C(I2CBus)
+
+	if err != nil {
+		return
+	}
+
+	return New(bus, I2CAddress), bus, nil
+}
+
+// bus returns the device transport, retrying and logging transfers.
+func (d *Device) bus() armoryctl.I2C {
+	return armoryctl.WithLog(armoryctl.WithRetry(d.Bus, Retry), "PF1510")
+}
+
 // Get device identifier and chip family reading I2C data address
 // 0x00: device_id <0:2>, family <3:7>
-func Info() (res string, err error) {
-	// Register DEVICE_ID - ADDR 0x00
-	// (p53, Table 52, PF1510 Datasheet).
-	val, err := armoryctl.I2CRead(I2CBus, I2CAddress, 0x00, 1)
+func (d *Device) Info() (res string, err error) {
+	id, err := Registers.ReadField(d.bus(), d.Address, "DEVICE_ID", "DEVICE_ID")
 
 	if err != nil {
 		return
 	}
 
-	id := val[0] & 0x07
-	family := (val[0] & 0xf8) >> 3
+	family, err := Registers.ReadField(d.bus(), d.Address, "DEVICE_ID", "FAMILY")
 
-	// Register OTP_FLAVOR - ADDR 0x01
-	// (p53, Table 52, PF1510 Datasheet).
-	otp, err := armoryctl.I2CRead(I2CBus, I2CAddress, 0x01, 1)
+	if err != nil {
+		return
+	}
+
+	otp, err := d.read("OTP_FLAVOR")
 
 	if err != nil {
 		return
 	}
 
-	// Register SILICON_REV - ADDR 0x02
-	// (p53, Table 54, PF1510 Datasheet).
-	rev, err := armoryctl.I2CRead(I2CBus, I2CAddress, 0x02, 1)
+	rev, err := d.read("SILICON_REV")
 
 	if err != nil {
 		return
@@ -68,3 +476,42 @@ func Info() (res string, err error) {
 
 	return
 }
+
+func (d *Device) read(name string) (val []byte, err error) {
+	r, err := Registers.Register(name)
+
+	if err != nil {
+		return
+	}
+
+	return r.Read(d.bus(), d.Address)
+}
+
+// Dump all registers, decoding their fields.
+func (d *Device) Dump() (res string, err error) {
+	return Registers.Dump(d.bus(), d.Address)
+}
+
+// Get device information of the default instance (see Device.Info).
+func Info() (res string, err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.Info()
+}
+
+// Dump all registers of the default instance (see Device.Dump).
+func Dump() (res string, err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.Dump()
+}
@@ -0,0 +1,49 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pf1510_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/usbarmory/armoryctl/pf1510"
+	"github.com/usbarmory/armoryctl/sim"
+)
+
+func TestInfo(t *testing.T) {
+	sim.Install(t, sim.NewBus())
+
+	res, err := pf1510.Info()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `id:0x4("PF1510") family:0xf("15") otp:"A1" rev:0x10`; res != want {
+		t.Errorf("unexpected info %s, want %s", res, want)
+	}
+}
+
+func TestDump(t *testing.T) {
+	sim.Install(t, sim.NewBus())
+
+	res, err := pf1510.Dump()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(strings.Split(res, "\n")); n != len(pf1510.Registers) {
+		t.Errorf("dumped %d registers, want %d", n, len(pf1510.Registers))
+	}
+
+	if want := `DEVICE_ID:0x4("PF1510")`; !strings.Contains(res, want) {
+		t.Errorf("dump does not contain %s:\n%s", want, res)
+	}
+}
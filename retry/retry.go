@@ -0,0 +1,54 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package retry configures the retry policy of I²C transfers, ATECC608
+// commands and ANNA-B112 AT commands, along with the error classes it relies
+// on.
+//
+// The global policy applies to all drivers, each driver package can override
+// it with its own Retry variable (e.g. atecc608.Retry).
+package retry
+
+import (
+	"github.com/usbarmory/armoryctl/internal"
+)
+
+// Policy represents a retry policy, errors are retried when transient (see
+// IsTransient) unless a different Retryable function is set.
+type Policy = armoryctl.RetryPolicy
+
+// Error classes, matched with errors.Is.
+var (
+	// the bus, or device node, is not present
+	ErrDeviceMissing = armoryctl.ErrDeviceMissing
+	// the slave did not acknowledge the transfer
+	ErrNACK = armoryctl.ErrNACK
+	// the response failed integrity checks
+	ErrChecksum = armoryctl.ErrChecksum
+	// the device reported an error status
+	ErrDeviceStatus = armoryctl.ErrDeviceStatus
+	// the device did not respond in time
+	ErrTimeout = armoryctl.ErrTimeout
+)
+
+// Global returns the global retry policy.
+func Global() *Policy {
+	return armoryctl.Retry
+}
+
+// SetGlobal sets the global retry policy, a nil policy disables retries for
+// drivers without a policy of their own.
+func SetGlobal(p *Policy) {
+	armoryctl.Retry = p
+}
+
+// IsTransient returns whether an error is of a transient class (NACK,
+// checksum or timeout).
+func IsTransient(err error) bool {
+	return armoryctl.IsTransient(err)
+}
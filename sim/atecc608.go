@@ -0,0 +1,329 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/usbarmory/armoryctl/internal"
+)
+
+// ATECC608 word addresses
+// (p50, Table 7-2, ATECC608A Full Datasheet).
+const (
+	ateccReset   = 0x00
+	ateccSleep   = 0x01
+	ateccIdle    = 0x02
+	ateccCommand = 0x03
+)
+
+// ATECC608 status/error codes
+// (p64-65, Tab 10-3, ATECC608A Full Datasheet).
+const (
+	ateccSuccess        = 0x00
+	ateccParseError     = 0x03
+	ateccExecutionError = 0x0f
+	ateccAfterWake      = 0x11
+	ateccCRCError       = 0xff
+)
+
+// ATECC608 device states.
+const (
+	ateccAsleep = iota
+	ateccIdling
+	ateccAwake
+)
+
+// ATECC608Watchdog is the simulated watchdog timeout, after which an awake
+// device goes to sleep
+// (p56, 9.3 AC Parameters: All I/O Interfaces, ATECC608A Full Datasheet).
+const ATECC608Watchdog = 1300 * time.Millisecond
+
+// ATECC608 represents a simulated ATECC608 secure element, modeling the I²C
+// packet framing (count and CRC16), the wake/idle/sleep states and a subset
+// of the command set.
+type ATECC608 struct {
+	sync.Mutex
+
+	// Config holds the configuration zone.
+	Config [128]byte
+	// OTP holds the one time programmable zone.
+	OTP [64]byte
+	// Data holds the data zone slots.
+	Data [16][]byte
+	// Revision holds the value returned by the Info command.
+	Revision [4]byte
+	// SelfTest holds the failure bit mask returned by the SelfTest
+	// command.
+	SelfTest byte
+	// Rand is the source of the Random command output, crypto/rand is
+	// used when nil.
+	Rand io.Reader
+
+	// Watchdog sets the timeout after which an awake device goes to
+	// sleep, the default ATECC608Watchdog is used when zero.
+	Watchdog time.Duration
+
+	state int
+	awake time.Time
+	out   []byte
+}
+
+// NewATECC608 returns a simulated ATECC608B secure element with unlocked
+// configuration and data zones.
+func NewATECC608() (dev *ATECC608) {
+	dev = &ATECC608{
+		Revision: [4]byte{0x00, 0x00, 0x60, 0x03},
+	}
+
+	// SN[0:3]
+	copy(dev.Config[0:], []byte{0x01, 0x23, 0x6d, 0x2a})
+	// RevNum
+	copy(dev.Config[4:], dev.Revision[:])
+	// SN[4:8]
+	copy(dev.Config[8:], []byte{0x5c, 0x1f, 0x89, 0x3b, 0xee})
+	// I2C_Enable
+	dev.Config[14] = 0x01
+	// I2C_Address
+	dev.Config[16] = 0x60 << 1
+	// LockValue, LockConfig
+	dev.Config[86] = 0x55
+	dev.Config[87] = 0x55
+	// SlotLocked
+	dev.Config[88] = 0xff
+	dev.Config[89] = 0xff
+
+	// Slot sizes (Data Zone, ATECC608A Full Datasheet).
+	for slot := range dev.Data {
+		switch {
+		case slot < 8:
+			dev.Data[slot] = make([]byte, 36)
+		case slot == 8:
+			dev.Data[slot] = make([]byte, 416)
+		default:
+			dev.Data[slot] = make([]byte, 72)
+		}
+	}
+
+	return
+}
+
+func crc16(data []byte) []byte {
+	var crc uint16
+
+	for _, b := range data {
+		for i := 0; i < 8; i++ {
+			if uint16(b>>i&1) != crc>>15 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return []byte{byte(crc), byte(crc >> 8)}
+}
+
+func (dev *ATECC608) checkWatchdog() {
+	timeout := dev.Watchdog
+
+	if timeout == 0 {
+		timeout = ATECC608Watchdog
+	}
+
+	if dev.state == ateccAwake && time.Since(dev.awake) > timeout {
+		dev.state = ateccAsleep
+		dev.out = nil
+	}
+}
+
+func (dev *ATECC608) respond(data ...byte) {
+	dev.out = append([]byte{byte(len(data) + 3)}, data...)
+	dev.out = append(dev.out, crc16(dev.out)...)
+}
+
+// Read returns size bytes from the device output buffer.
+func (dev *ATECC608) Read(reg uint8, size uint) (val []byte, err error) {
+	dev.Lock()
+	defer dev.Unlock()
+
+	dev.checkWatchdog()
+
+	if dev.state != ateccAwake {
+		return nil, armoryctl.Classify(errors.New("device not awake (NACK)"), armoryctl.ErrNACK)
+	}
+
+	val = make([]byte, size)
+
+	for i := range val {
+		if i < len(dev.out) {
+			val[i] = dev.out[i]
+		} else {
+			val[i] = 0xff
+		}
+	}
+
+	return
+}
+
+// Write handles wake tokens, word address transitions and command packets.
+func (dev *ATECC608) Write(reg uint8, val []byte) (err error) {
+	dev.Lock()
+	defer dev.Unlock()
+
+	dev.checkWatchdog()
+
+	if dev.state != ateccAwake {
+		if reg == ateccReset {
+			// The wake token is never acknowledged
+			// (p47, 7.1 I/O Conditions, ATECC608A Full Datasheet).
+			dev.state = ateccAwake
+			dev.awake = time.Now()
+			dev.respond(ateccAfterWake)
+		}
+
+		return armoryctl.Classify(errors.New("device not awake (NACK)"), armoryctl.ErrNACK)
+	}
+
+	switch reg {
+	case ateccSleep:
+		dev.state = ateccAsleep
+		dev.out = nil
+	case ateccIdle:
+		dev.state = ateccIdling
+	case ateccCommand:
+		dev.execute(val)
+	}
+
+	return
+}
+
+func (dev *ATECC608) execute(pkt []byte) {
+	// ATECC cmd packet format:
+	//   count [1] | opcode [1] | param1 [1] | param2 [2] | data [variable] | crc16 [2]
+	//
+	// (p63, Table 10-1, ATECC608A Full Datasheet)
+	if len(pkt) < 7 || int(pkt[0]) != len(pkt) {
+		dev.respond(ateccCRCError)
+		return
+	}
+
+	size := len(pkt) - 2
+
+	if crc := crc16(pkt[:size]); crc[0] != pkt[size] || crc[1] != pkt[size+1] {
+		dev.respond(ateccCRCError)
+		return
+	}
+
+	opcode := pkt[1]
+	param1 := pkt[2]
+	param2 := uint16(pkt[3]) | uint16(pkt[4])<<8
+
+	switch opcode {
+	case 0x02: // Read
+		dev.read(param1, param2)
+	case 0x30: // Info
+		if param1 != 0x00 {
+			dev.respond(ateccParseError)
+			return
+		}
+
+		dev.respond(dev.Revision[:]...)
+	case 0x1b: // Random
+		dev.random(param1, param2, pkt[5:size])
+	case 0x77: // SelfTest
+		dev.respond(dev.SelfTest & param1)
+	default:
+		dev.respond(ateccParseError)
+	}
+}
+
+func (dev *ATECC608) random(param1 byte, param2 uint16, data []byte) {
+	if param1 != 0x00 || param2 != 0x0000 || len(data) != 20 {
+		dev.respond(ateccParseError)
+		return
+	}
+
+	// An unlocked configuration zone results in a fixed test pattern.
+	if dev.Config[87] == 0x55 {
+		dev.respond(bytes.Repeat([]byte{0xff, 0xff, 0x00, 0x00}, 8)...)
+		return
+	}
+
+	r := dev.Rand
+
+	if r == nil {
+		r = rand.Reader
+	}
+
+	buf := make([]byte, 32)
+
+	if _, err := io.ReadFull(r, buf); err != nil {
+		dev.respond(ateccExecutionError)
+		return
+	}
+
+	dev.respond(buf...)
+}
+
+func (dev *ATECC608) read(param1 byte, param2 uint16) {
+	var zone []byte
+
+	size := 4
+	block := int(param2>>3) & 0x03
+	offset := int(param2) & 0x07
+
+	// Data and OTP zones cannot be read before the data zone is locked.
+	if param1&0x03 != 0x00 && dev.Config[86] == 0x55 {
+		dev.respond(ateccExecutionError)
+		return
+	}
+
+	// Zone encoding and address encoding
+	// (p87, Table 11-36, ATECC608A Full Datasheet).
+	switch param1 & 0x03 {
+	case 0x00:
+		zone = dev.Config[:]
+	case 0x01:
+		zone = dev.OTP[:]
+	case 0x02:
+		slot := int(param2>>3) & 0x0f
+
+		// IsSecret slots cannot be read in clear text.
+		if dev.Config[20+slot*2]&0x80 != 0 {
+			dev.respond(ateccExecutionError)
+			return
+		}
+
+		zone = dev.Data[slot]
+		block = int(param2>>8) & 0x0f
+	default:
+		dev.respond(ateccParseError)
+		return
+	}
+
+	if param1&0x80 != 0 {
+		size = 32
+		offset = 0
+	}
+
+	start := block*32 + offset*4
+
+	if start+size > len(zone) {
+		dev.respond(ateccParseError)
+		return
+	}
+
+	dev.respond(zone[start : start+size]...)
+}
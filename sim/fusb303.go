@@ -0,0 +1,32 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package sim
+
+// NewFUSB303 returns a simulated FUSB303 controller register map, in disabled
+// state and reporting a 0.5 A current advertisement
+// (FUSB303/D, Tables 13-22).
+func NewFUSB303() (r *Registers) {
+	r = &Registers{}
+
+	// DEVICE ID
+	r.Map[0x01] = 0x10
+
+	// CONTROL_1
+	r.Map[0x05] = 0xb3
+	r.Mask[0x05] = 0xff
+
+	// STATUS: BC_LVL[1:0]
+	r.Map[0x11] = 0x01 << 1
+
+	// INTERRUPT, INTERRUPT1 (W1C)
+	r.Clear[0x14] = 0x7f
+	r.Clear[0x15] = 0x6f
+
+	return
+}
@@ -0,0 +1,24 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package sim
+
+// NewPF1510 returns a simulated PF1510 PMIC register map
+// (p53, Table 52, PF1510 Datasheet).
+func NewPF1510() (r *Registers) {
+	r = &Registers{}
+
+	// DEVICE_ID: family <3:7>, device_id <0:2>
+	r.Map[0x00] = 15<<3 | 0x4
+	// OTP_FLAVOR
+	r.Map[0x01] = 0x01
+	// SILICON_REV
+	r.Map[0x02] = 0x10
+
+	return
+}
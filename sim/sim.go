@@ -0,0 +1,169 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package sim provides in-memory models of the USB armory Mk II on-board I²C
+// slaves, allowing the chip drivers to be exercised without hardware.
+//
+// A simulated bus can be passed to the chip drivers instance constructors
+// (e.g. fusb303.New) or installed in place of a system bus with
+// i2c.SetBackend:
+//
+//	bus := sim.NewBus()
+//	i2c.SetBackend(0, bus)
+//	defer i2c.SetBackend(0, nil)
+//
+//	mode, err := tusb320.GetCurrentMode()
+//
+// Within tests Install takes care of restoring the system transport.
+package sim
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/usbarmory/armoryctl/internal"
+)
+
+// Device represents a simulated I²C slave device.
+type Device interface {
+	// Read returns size bytes starting from register reg.
+	Read(reg uint8, size uint) (val []byte, err error)
+	// Write writes val starting at register reg.
+	Write(reg uint8, val []byte) (err error)
+}
+
+// Bus represents a simulated I²C bus, it implements the transport interface
+// required by the chip drivers.
+type Bus struct {
+	sync.Mutex
+
+	devices map[int]Device
+}
+
+// NewBus returns a simulated I²C bus populated with the USB armory Mk II
+// on-board slaves at their default addresses.
+func NewBus() (bus *Bus) {
+	bus = &Bus{}
+
+	bus.Attach(0x08, NewPF1510())
+	bus.Attach(0x31, NewFUSB303())
+	bus.Attach(0x60, NewATECC608())
+	bus.Attach(0x61, NewTUSB320())
+
+	return
+}
+
+// Attach connects a device at the given slave address, a nil device removes
+// any device present at that address.
+func (b *Bus) Attach(addr int, dev Device) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.devices == nil {
+		b.devices = make(map[int]Device)
+	}
+
+	if dev == nil {
+		delete(b.devices, addr)
+	} else {
+		b.devices[addr] = dev
+	}
+}
+
+// Device returns the device connected at the given slave address.
+func (b *Bus) Device(addr int) (dev Device, ok bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	dev, ok = b.devices[addr]
+
+	return
+}
+
+func (b *Bus) device(addr int) (dev Device, err error) {
+	dev, ok := b.Device(addr)
+
+	if !ok {
+		err = armoryctl.Classify(fmt.Errorf("no device at address %#x (NACK)", addr), armoryctl.ErrNACK)
+	}
+
+	return
+}
+
+// Read reads size bytes from register reg of the slave at address addr.
+func (b *Bus) Read(addr int, reg uint8, size uint) (val []byte, err error) {
+	dev, err := b.device(addr)
+
+	if err != nil {
+		return
+	}
+
+	return dev.Read(reg, size)
+}
+
+// Write writes val to register reg of the slave at address addr.
+func (b *Bus) Write(addr int, reg uint8, val []byte) (err error) {
+	dev, err := b.device(addr)
+
+	if err != nil {
+		return
+	}
+
+	return dev.Write(reg, val)
+}
+
+// Probe returns an error, matching armoryctl.ErrNACK, when no device is
+// connected at the given slave address.
+func (b *Bus) Probe(addr int) (err error) {
+	_, err = b.device(addr)
+	return
+}
+
+// Registers represents a simulated register mapped device, the register
+// address is automatically incremented on multi-byte transfers.
+type Registers struct {
+	sync.Mutex
+
+	// Map holds the register values.
+	Map [256]byte
+	// Mask holds, for each register, the bits which can be written.
+	Mask [256]byte
+	// Clear holds, for each register, the bits which are cleared by
+	// writing 1 (e.g. interrupt flags).
+	Clear [256]byte
+}
+
+// Read returns size bytes starting from register reg.
+func (r *Registers) Read(reg uint8, size uint) (val []byte, err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	val = make([]byte, size)
+
+	for i := range val {
+		val[i] = r.Map[reg]
+		reg++
+	}
+
+	return
+}
+
+// Write writes val starting at register reg, read-only bits are left
+// unchanged and write-1-to-clear bits are cleared when written as 1.
+func (r *Registers) Write(reg uint8, val []byte) (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, v := range val {
+		r.Map[reg] = (r.Map[reg] & ^r.Mask[reg]) | (v & r.Mask[reg])
+		r.Map[reg] &^= v & r.Clear[reg]
+		reg++
+	}
+
+	return
+}
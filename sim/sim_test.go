@@ -0,0 +1,68 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package sim_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/usbarmory/armoryctl/atecc608"
+	"github.com/usbarmory/armoryctl/fusb303"
+	"github.com/usbarmory/armoryctl/pf1510"
+	"github.com/usbarmory/armoryctl/retry"
+	"github.com/usbarmory/armoryctl/sim"
+	"github.com/usbarmory/armoryctl/tusb320"
+)
+
+func TestMissingDevice(t *testing.T) {
+	tests := []struct {
+		name string
+		addr int
+		fn   func() error
+	}{
+		{"pf1510", pf1510.I2CAddress, func() (err error) { _, err = pf1510.Info(); return }},
+		{"fusb303", fusb303.I2CAddress, func() (err error) { _, err = fusb303.GetCurrentMode(); return }},
+		{"atecc608", atecc608.I2CAddress, func() (err error) { _, err = atecc608.Info(); return }},
+		{"tusb320", tusb320.I2CAddress, func() (err error) { _, err = tusb320.GetCurrentMode(); return }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := sim.NewBus()
+			bus.Attach(tt.addr, nil)
+			sim.Install(t, bus)
+
+			if err := tt.fn(); !errors.Is(err, retry.ErrNACK) {
+				t.Errorf("expected NACK, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRegisters(t *testing.T) {
+	r := &sim.Registers{}
+	r.Map[0x10] = 0xf0
+	r.Mask[0x10] = 0x0f
+	r.Mask[0x11] = 0xff
+
+	if err := r.Write(0x10, []byte{0xab, 0xcd}); err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := r.Read(0x10, 2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// read-only bits are preserved, the address auto-increments
+	if val[0] != 0xfb || val[1] != 0xcd {
+		t.Errorf("unexpected register values %#x", val)
+	}
+}
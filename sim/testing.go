@@ -0,0 +1,29 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"testing"
+
+	"github.com/usbarmory/armoryctl/internal"
+)
+
+// I2CBus represents the system I²C bus number the USB armory Mk II on-board
+// slaves are connected to.
+const I2CBus = 0
+
+// Install replaces the transport of the on-board system I²C bus with the
+// argument simulated bus for the duration of the test, the system transport
+// is restored on test cleanup.
+func Install(t testing.TB, bus *Bus) {
+	t.Helper()
+
+	armoryctl.SetI2CBackend(I2CBus, bus)
+	t.Cleanup(func() { armoryctl.SetI2CBackend(I2CBus, nil) })
+}
@@ -0,0 +1,32 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package sim
+
+// NewTUSB320 returns a simulated TUSB320 controller register map, reporting a
+// 1.5 A current advertisement
+// (SLLSEN9E, Table 7).
+func NewTUSB320() (r *Registers) {
+	r = &Registers{}
+
+	// DEVICE_ID, "TUSB320" in ASCII from address 0x06 down to 0x00
+	copy(r.Map[0x00:], []byte{0x30, 0x32, 0x33, 0x42, 0x53, 0x55, 0x54, 0x00})
+
+	// CSR: CURRENT_MODE_ADVERTISE (RW), CURRENT_MODE_DETECT
+	r.Map[0x08] = 0x01 << 4
+	r.Mask[0x08] = 0xc0
+
+	// CSR_1: INTERRUPT_STATUS (W1C), DRP_DUTY_CYCLE, DISABLE_UFP_ACCESSORY
+	r.Mask[0x09] = 0x07
+	r.Clear[0x09] = 0x10
+
+	// CSR: DEBOUNCE, MODE_SELECT, I2C_SOFT_RESET, SOURCE_PREF, DISABLE_TERM
+	r.Mask[0x0a] = 0xff
+
+	return
+}
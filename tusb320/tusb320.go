@@ -18,11 +18,17 @@ import (
 	"github.com/usbarmory/armoryctl/internal"
 )
 
+// Default instance bus number and address, used by the package level
+// functions.
 var (
 	I2CBus     = 0
 	I2CAddress = 0x61
 )
 
+// Retry sets the I²C transfer retry policy, the global one is used when nil
+// (see armoryctl.Retry).
+var Retry *armoryctl.RetryPolicy
+
 // Current mode values and meaning (SSLSEN9E, Table 7).
 var CurrentMode = map[byte]string{
 	0x00: "0.5 A",
@@ -31,6 +37,109 @@ var CurrentMode = map[byte]string{
 	0x03: "3.0 A",
 }
 
+// Register map (SLLSEN9E, Tables 7 to 9).
+var Registers = armoryctl.RegisterMap{
+	{
+		Name:    "DEVICE_ID",
+		Address: 0x00,
+		Size:    8,
+		Access:  armoryctl.ReadOnly,
+	},
+	{
+		Name:    "CSR",
+		Address: 0x08,
+		Fields: []*armoryctl.Field{
+			{
+				Name:  "CURRENT_MODE_ADVERTISE",
+				Shift: 6,
+				Width: 2,
+				Values: map[byte]string{
+					0x00: "default",
+					0x01: "mid",
+					0x02: "high",
+				},
+			},
+			{Name: "CURRENT_MODE_DETECT", Shift: 4, Width: 2, Access: armoryctl.ReadOnly, Values: CurrentMode},
+			{Name: "ACCESSORY_CONNECTED", Shift: 1, Width: 3, Access: armoryctl.ReadOnly},
+			{Name: "ACTIVE_CABLE_DETECTION", Shift: 0, Width: 1, Access: armoryctl.ReadOnly},
+		},
+	},
+	{
+		Name:    "CSR_1",
+		Address: 0x09,
+		Fields: []*armoryctl.Field{
+			{
+				Name:   "ATTACHED_STATE",
+				Shift:  6,
+				Width:  2,
+				Access: armoryctl.ReadOnly,
+				Values: map[byte]string{
+					0x00: "not attached",
+					0x01: "attached.SRC",
+					0x02: "attached.SNK",
+					0x03: "attached to accessory",
+				},
+			},
+			{Name: "CABLE_DIR", Shift: 5, Width: 1, Access: armoryctl.ReadOnly},
+			{Name: "INTERRUPT_STATUS", Shift: 4, Width: 1, Access: armoryctl.WriteOneToClear},
+			{Name: "DRP_DUTY_CYCLE", Shift: 1, Width: 2},
+			{Name: "DISABLE_UFP_ACCESSORY", Shift: 0, Width: 1},
+		},
+	},
+	{
+		Name:    "CSR_2",
+		Address: 0x0a,
+		Fields: []*armoryctl.Field{
+			{Name: "DEBOUNCE", Shift: 6, Width: 2},
+			{
+				Name:  "MODE_SELECT",
+				Shift: 4,
+				Width: 2,
+				Values: map[byte]string{
+					0x00: "PORT pin",
+					0x01: "UFP",
+					0x02: "DFP",
+					0x03: "DRP",
+				},
+			},
+			{Name: "I2C_SOFT_RESET", Shift: 3, Width: 1},
+			{Name: "SOURCE_PREF", Shift: 1, Width: 2},
+			{Name: "DISABLE_TERM", Shift: 0, Width: 1},
+		},
+	},
+}
+
+// Device represents a TUSB320 controller instance.
+type Device struct {
+	// I²C bus transport
+	Bus armoryctl.I2C
+	// I²C slave address
+	Address int
+}
+
+// New returns a TUSB320 controller instance on the given bus and address.
+func New(bus armoryctl.I2C, addr int) *Device {
+	return &Device{
+		Bus:     bus,
+		Address: addr,
+	}
+}
+
+func openDefault() (d *Device, bus armoryctl.I2CHandle, err error) {
+	bus, err = armoryctl.OpenI2C(I2CBus)
+
+	if err != nil {
+		return
+	}
+
+	return New(bus, I2CAddress), bus, nil
+}
+
+// bus returns the device transport, retrying and logging transfers.
+func (d *Device) bus() armoryctl.I2C {
+	return armoryctl.WithLog(armoryctl.WithRetry(d.Bus, Retry), "TUSB320")
+}
+
 func reverse(val []byte) []byte {
 	for i := len(val)/2 - 1; i >= 0; i-- {
 		rev := len(val) - 1 - i
@@ -42,21 +151,62 @@ func reverse(val []byte) []byte {
 
 // Get device identifier, reading I2C data address 0x00 - 0x07
 // (SLLSEN9E, Table 7).
-func GetDeviceID() (id []byte, err error) {
-	id, err = armoryctl.I2CRead(I2CBus, I2CAddress, 0x00, 8)
+func (d *Device) GetDeviceID() (id []byte, err error) {
+	r, err := Registers.Register("DEVICE_ID")
+
+	if err != nil {
+		return
+	}
+
+	id, err = r.Read(d.bus(), d.Address)
+
 	return reverse(id), err
 }
 
 // Get detected current advertisement, reading I2C data address 0x08 (CSR,
-// (SLLSEN9E, Table 7) and extracting value CURRENT_MODE_ADVERTISE.
+// (SLLSEN9E, Table 7) and extracting value CURRENT_MODE_DETECT.
+func (d *Device) GetCurrentMode() (mode byte, err error) {
+	return Registers.ReadField(d.bus(), d.Address, "CSR", "CURRENT_MODE_DETECT")
+}
+
+// Dump all registers, decoding their fields.
+func (d *Device) Dump() (res string, err error) {
+	return Registers.Dump(d.bus(), d.Address)
+}
+
+// Get device identifier of the default instance (see Device.GetDeviceID).
+func GetDeviceID() (id []byte, err error) {
+	d, bus, err := openDefault()
+
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.GetDeviceID()
+}
+
+// Get detected current advertisement of the default instance (see
+// Device.GetCurrentMode).
 func GetCurrentMode() (mode byte, err error) {
-	val, err := armoryctl.I2CRead(I2CBus, I2CAddress, 0x08, 1)
+	d, bus, err := openDefault()
 
 	if err != nil {
 		return
 	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
+
+	return d.GetCurrentMode()
+}
+
+// Dump all registers of the default instance (see Device.Dump).
+func Dump() (res string, err error) {
+	d, bus, err := openDefault()
 
-	mode = (val[0] >> 4) & 3
+	if err != nil {
+		return
+	}
+	defer func() { _ = bus.Close() }() // make errcheck happy
 
-	return
+	return d.Dump()
 }
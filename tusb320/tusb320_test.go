@@ -0,0 +1,90 @@
+// armoryctl | https://github.com/usbarmory/armoryctl
+//
+// USB armory Mk II - hardware control tool
+// Copyright (c) The armoryctl authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package tusb320_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/usbarmory/armoryctl/sim"
+	"github.com/usbarmory/armoryctl/tusb320"
+)
+
+func TestGetDeviceID(t *testing.T) {
+	sim.Install(t, sim.NewBus())
+
+	id, err := tusb320.GetDeviceID()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.Trim(string(id), "\x00"); s != "TUSB320" {
+		t.Errorf("unexpected device ID %q", s)
+	}
+}
+
+func TestGetCurrentMode(t *testing.T) {
+	sim.Install(t, sim.NewBus())
+
+	mode, err := tusb320.GetCurrentMode()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tusb320.CurrentMode[mode] != "1.5 A" {
+		t.Errorf("unexpected current mode %#x", mode)
+	}
+}
+
+func TestDump(t *testing.T) {
+	sim.Install(t, sim.NewBus())
+
+	res, err := tusb320.Dump()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"CSR(0x08):0x10 ",
+		`CURRENT_MODE_DETECT:0x1("1.5 A")`,
+		`ATTACHED_STATE:0x0("not attached")`,
+	} {
+		if !strings.Contains(res, want) {
+			t.Errorf("dump does not contain %s:\n%s", want, res)
+		}
+	}
+}
+
+func TestInterruptStatus(t *testing.T) {
+	bus := sim.NewBus()
+	dev := sim.NewTUSB320()
+	bus.Attach(tusb320.I2CAddress, dev)
+
+	// pending interrupt
+	dev.Map[0x09] = 0x10
+
+	if err := tusb320.Registers.WriteField(bus, tusb320.I2CAddress, "CSR_1", "DRP_DUTY_CYCLE", 2, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := tusb320.Registers.ReadField(bus, tusb320.I2CAddress, "CSR_1", "INTERRUPT_STATUS"); err != nil || v != 1 {
+		t.Errorf("pending interrupt not preserved (%v)", err)
+	}
+
+	if err := tusb320.Registers.WriteField(bus, tusb320.I2CAddress, "CSR_1", "INTERRUPT_STATUS", 1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if dev.Map[0x09] != 2<<1 {
+		t.Errorf("unexpected CSR_1 value %#x", dev.Map[0x09])
+	}
+}